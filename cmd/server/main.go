@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,79 +14,230 @@ import (
 	"time"
 
 	"paysystem/internal/config"
+	"paysystem/internal/consumer"
 	"paysystem/internal/handler"
 	"paysystem/internal/infrastructure/cache"
 	"paysystem/internal/infrastructure/database"
+	"paysystem/internal/infrastructure/election"
+	"paysystem/internal/infrastructure/health"
+	"paysystem/internal/infrastructure/lifecycle"
+	"paysystem/internal/infrastructure/lock"
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/infrastructure/metrics"
 	"paysystem/internal/infrastructure/mq"
+	"paysystem/internal/infrastructure/tracing"
 	"paysystem/internal/job"
+	"paysystem/internal/repository"
 	"paysystem/pkg/idgen"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
 )
 
 func main() {
 	// 加载配置
 	cfg := config.LoadConfig("config/config.yaml")
 
+	// 初始化日志（之后标准库 log 的输出也走结构化日志）
+	logging.Init(&cfg.Log)
+
+	// 收到退出信号后 ctx 取消，由生命周期管理器按依赖逆序关闭各组件
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg); err != nil {
+		slog.Error("服务异常退出", "err", err)
+		stop()
+		os.Exit(1)
+	}
+	slog.Info("服务已关闭")
+}
+
+// run 初始化并按依赖顺序登记各组件，阻塞到收到退出信号且所有组件关闭
+func run(ctx context.Context, cfg *config.Config) error {
+	stopTimeout := time.Duration(cfg.Server.ShutdownTimeoutSeconds) * time.Second
+	if stopTimeout <= 0 {
+		stopTimeout = 30 * time.Second
+	}
+	app := lifecycle.New(stopTimeout)
+
+	// 初始化链路追踪（最先启动、最后关闭，关闭时刷出未导出的 span）
+	shutdownTracing := tracing.Init(&cfg.Tracing)
+	app.Append(lifecycle.Hook{Name: "tracing", OnStop: shutdownTracing})
+
 	// 初始化 ID 生成器
 	idgen.Init(1)
 
 	// 初始化 MySQL
 	db := database.InitMySQL(&cfg.MySQL)
+	app.Append(lifecycle.Hook{Name: "mysql", OnStop: func(context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	}})
 
 	// 初始化 Redis
 	redisClient := cache.InitRedis(&cfg.Redis)
+	redlockClients := cache.NewRedlockClients(&cfg.Redis)
+	redisClients := append([]*redis.Client{redisClient}, redlockClients...)
+	app.Append(lifecycle.Hook{Name: "redis", OnStop: func(context.Context) error {
+		var errs []error
+		for _, client := range redisClients {
+			errs = append(errs, client.Close())
+		}
+		return errors.Join(errs...)
+	}})
 
-	// 初始化 Kafka
-	mq.InitKafka(&cfg.Kafka)
-	defer mq.CloseKafka()
+	// MySQL、Redis 链路追踪
+	if cfg.Tracing.Enabled {
+		if err := db.Use(tracing.NewGormPlugin()); err != nil {
+			log.Fatalf("注册 GORM 链路追踪插件失败: %v", err)
+		}
+		for _, client := range redisClients {
+			client.AddHook(tracing.NewRedisHook(client.Options().Addr))
+		}
+	}
 
-	// 创建上下文（用于优雅关闭）
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// 初始化锁提供者（Redis 不可用时按配置降级）
+	locker := lock.NewLocker(&cfg.Lock, redisClient, redlockClients)
 
-	// 启动后台任务
-	outboxSender := job.NewOutboxSender(db, cfg)
-	go outboxSender.Start(ctx)
+	// 初始化 Kafka（发送方都停止后才关闭，关闭时等待已提交的消息发送完成）
+	mq.InitKafka(&cfg.Kafka)
+	app.Append(lifecycle.Hook{Name: "kafka_producer", OnStop: func(context.Context) error {
+		return mq.CloseKafka()
+	}})
 
-	orderTimeoutJob := job.NewOrderTimeoutJob(db, cfg)
-	go orderTimeoutJob.Start(ctx)
+	// 后台任务：关闭时等待当前批次处理完，OutboxSender 在 Kafka 关闭前再补发一轮
+	app.Append(lifecycle.Background("outbox_sender", job.NewOutboxSender(db, cfg)))
 
-	compensateJob := job.NewPayingOrderCompensateJob(db, cfg)
-	go compensateJob.Start(ctx)
+	// 超时关单、支付中补偿只在主节点执行：选举先于任务启动、晚于任务关闭，
+	// 任务处理完当前批次后再释放租约，其他实例随即接管
+	timeoutLeader := election.New(redisClient, "order_timeout", &cfg.Leader)
+	app.Append(lifecycle.Background("order_timeout_election", timeoutLeader))
+	app.Append(lifecycle.Background("order_timeout", job.NewOrderTimeoutJob(db, redisClient, timeoutLeader, cfg)))
 
-	// 设置路由
-	router := handler.SetupRouter(db, redisClient, cfg)
+	compensateLeader := election.New(redisClient, "paying_order_compensate", &cfg.Leader)
+	app.Append(lifecycle.Background("paying_order_compensate_election", compensateLeader))
+	app.Append(lifecycle.Background("paying_order_compensate", job.NewPayingOrderCompensateJob(db, redisClient, compensateLeader, cfg)))
 
-	// 启动 HTTP 服务
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
-		Handler: router,
+	if cfg.Outbox.Retention.Enabled {
+		app.Append(lifecycle.Background("outbox_retention", job.NewOutboxRetentionJob(db, cfg)))
 	}
 
-	// 在 goroutine 中启动服务器
-	go func() {
-		log.Printf("服务启动，监听端口: %d", cfg.Server.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("服务启动失败: %v", err)
+	// 上游命令消费
+	if cfg.Kafka.Consumer.Enabled {
+		commandConsumer := consumer.NewCommandConsumer(db, locker, cfg)
+		kafkaConsumer, err := mq.NewConsumer(cfg.Kafka.Brokers, &cfg.Kafka.Consumer, commandConsumer.Handle)
+		if err != nil {
+			log.Fatalf("创建 Kafka 消费者失败: %v", err)
 		}
-	}()
-
-	// 等待中断信号
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+		app.Append(lifecycle.Background("kafka_consumer", kafkaConsumer))
+	}
 
-	log.Println("正在关闭服务...")
+	// 注册连接池、Outbox 积压指标
+	if cfg.Metrics.Enabled {
+		registerMetrics(db, redisClient, redlockClients)
+	}
 
-	// 取消上下文，停止后台任务
-	cancel()
+	// 注册就绪检查
+	registerHealthChecks(db, redisClient, &cfg.Health)
+
+	// HTTP 服务最后启动、最先关闭：先摘流量，再等在途请求（包括执行中的支付）处理完
+	// 路由在启动时创建，鉴权配置有误时启动失败，已启动的组件按逆序关闭
+	server := &http.Server{Addr: fmt.Sprintf(":%d", cfg.Server.Port)}
+	app.Append(lifecycle.Hook{
+		Name: "http",
+		OnStart: func(context.Context) error {
+			router, err := handler.SetupRouter(db, redisClient, locker, cfg)
+			if err != nil {
+				return err
+			}
+			server.Handler = router
+
+			listener, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				return err
+			}
+			slog.Info("服务启动", "port", cfg.Server.Port)
+			go func() {
+				if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					app.Fail(fmt.Errorf("HTTP 服务异常退出: %w", err))
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			// 先返回未就绪，等负载均衡摘掉流量后再停止接收请求
+			health.SetShuttingDown()
+			if delay := time.Duration(cfg.Health.ShutdownDelaySeconds) * time.Second; delay > 0 {
+				slog.Info("等待摘除流量", "delay", delay)
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+				}
+			}
+			return server.Shutdown(ctx)
+		},
+	})
+
+	return app.Run(ctx)
+}
 
-	// 关闭 HTTP 服务（等待最多5秒）
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer shutdownCancel()
+func registerMetrics(db *gorm.DB, redisClient *redis.Client, redlockClients []*redis.Client) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("获取底层 DB 失败: %v", err)
+	}
+	metrics.RegisterDBStats("paysystem", sqlDB)
+	metrics.RegisterRedisPool("main", redisClient)
+	for i, client := range redlockClients {
+		metrics.RegisterRedisPool(fmt.Sprintf("redlock-%d", i), client)
+	}
+	metrics.RegisterOutboxBacklog(repository.NewOutboxRepository(db).PendingStats)
+}
 
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Printf("服务关闭异常: %v", err)
+func registerHealthChecks(db *gorm.DB, redisClient *redis.Client, cfg *config.HealthConfig) {
+	// 依赖默认都是关键项，连不上依赖的实例摘掉流量；只降级不摘流量需要在配置中明确去掉
+	critical := cfg.Critical
+	if len(critical) == 0 {
+		critical = []string{"mysql", "redis", "kafka", "outbox"}
 	}
+	health.Configure(time.Duration(cfg.TimeoutMs)*time.Millisecond, cfg.JobStaleFactor, critical)
 
-	log.Println("服务已关闭")
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("获取底层 DB 失败: %v", err)
+	}
+	health.Register("mysql", func(ctx context.Context) (any, error) {
+		return nil, sqlDB.PingContext(ctx)
+	})
+	health.Register("redis", func(ctx context.Context) (any, error) {
+		return nil, redisClient.Ping(ctx).Err()
+	})
+	health.Register("kafka", func(ctx context.Context) (any, error) {
+		return nil, mq.Ping()
+	})
+
+	outboxRepo := repository.NewOutboxRepository(db)
+	maxAge := time.Duration(cfg.OutboxMaxAgeSeconds) * time.Second
+	health.Register("outbox", func(ctx context.Context) (any, error) {
+		count, oldest, err := outboxRepo.PendingStats(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var age time.Duration
+		if !oldest.IsZero() {
+			age = time.Since(oldest)
+		}
+		detail := map[string]any{"pending": count, "oldest_age_seconds": int64(age.Seconds())}
+		if cfg.OutboxMaxPending > 0 && count > cfg.OutboxMaxPending {
+			return detail, fmt.Errorf("待发送消息积压 %d 条，超过上限 %d", count, cfg.OutboxMaxPending)
+		}
+		if maxAge > 0 && age > maxAge {
+			return detail, fmt.Errorf("最早一条待发送消息已等待 %v，超过上限 %v", age.Truncate(time.Second), maxAge)
+		}
+		return detail, nil
+	})
 }
@@ -8,15 +8,28 @@ import (
 
 // Config 全局配置结构
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	MySQL    MySQLConfig    `mapstructure:"mysql"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	Kafka    KafkaConfig    `mapstructure:"kafka"`
-	Business BusinessConfig `mapstructure:"business"`
+	Server      ServerConfig      `mapstructure:"server"`
+	MySQL       MySQLConfig       `mapstructure:"mysql"`
+	Redis       RedisConfig       `mapstructure:"redis"`
+	Kafka       KafkaConfig       `mapstructure:"kafka"`
+	Business    BusinessConfig    `mapstructure:"business"`
+	Outbox      OutboxConfig      `mapstructure:"outbox"`
+	Lock        LockConfig        `mapstructure:"lock"`
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+	RateLimit   RateLimitConfig   `mapstructure:"rate_limit"`
+	Auth        AuthConfig        `mapstructure:"auth"`
+	JWT         JWTConfig         `mapstructure:"jwt"`
+	Admin       AdminConfig       `mapstructure:"admin"`
+	Metrics     MetricsConfig     `mapstructure:"metrics"`
+	Tracing     TracingConfig     `mapstructure:"tracing"`
+	Log         LogConfig         `mapstructure:"log"`
+	Health      HealthConfig      `mapstructure:"health"`
+	Leader      LeaderConfig      `mapstructure:"leader"`
 }
 
 type ServerConfig struct {
-	Port int `mapstructure:"port"`
+	Port                   int `mapstructure:"port"`
+	ShutdownTimeoutSeconds int `mapstructure:"shutdown_timeout_seconds"` // 关闭所有组件的总时长（含摘流量等待），默认 30
 }
 
 type MySQLConfig struct {
@@ -27,6 +40,7 @@ type MySQLConfig struct {
 	Database     string `mapstructure:"database"`
 	MaxOpenConns int    `mapstructure:"max_open_conns"`
 	MaxIdleConns int    `mapstructure:"max_idle_conns"`
+	SlowQueryMs  int    `mapstructure:"slow_query_ms"` // 超过该耗时的 SQL 记为慢查询日志，0 表示不记录
 }
 
 type RedisConfig struct {
@@ -34,16 +48,141 @@ type RedisConfig struct {
 	Port     int    `mapstructure:"port"`
 	Password string `mapstructure:"password"`
 	DB       int    `mapstructure:"db"`
+
+	// RedlockNodes Redlock 使用的独立 Redis 节点（lock.provider 为 redlock 时生效）
+	RedlockNodes []RedisNodeConfig `mapstructure:"redlock_nodes"`
+}
+
+type RedisNodeConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
 }
 
 type KafkaConfig struct {
-	Brokers []string         `mapstructure:"brokers"`
-	Topic   KafkaTopicConfig `mapstructure:"topic"`
+	Brokers  []string            `mapstructure:"brokers"`
+	Topic    KafkaTopicConfig    `mapstructure:"topic"`
+	Consumer KafkaConsumerConfig `mapstructure:"consumer"`
 }
 
 type KafkaTopicConfig struct {
-	PayResult    string `mapstructure:"pay_result"`
-	OrderTimeout string `mapstructure:"order_timeout"`
+	PayResult     string `mapstructure:"pay_result"`
+	RefundResult  string `mapstructure:"refund_result"`
+	OrderTimeout  string `mapstructure:"order_timeout"`
+	AccountChange string `mapstructure:"account_change"`
+}
+
+// LockConfig 锁配置
+type LockConfig struct {
+	Provider         string `mapstructure:"provider"`          // redis | redlock | mysql | local
+	WaitMode         string `mapstructure:"wait_mode"`         // Redis 锁等待方式：poll | fair
+	Fallback         string `mapstructure:"fallback"`          // 主实现不可用时的降级实现，为空不降级
+	FailureThreshold int    `mapstructure:"failure_threshold"` // 连续失败多少次触发熔断
+	OpenSeconds      int    `mapstructure:"open_seconds"`      // 熔断持续时间
+}
+
+// KafkaConsumerConfig 上游命令消费配置
+type KafkaConsumerConfig struct {
+	Enabled             bool   `mapstructure:"enabled"`
+	GroupID             string `mapstructure:"group_id"`
+	Topic               string `mapstructure:"topic"`
+	RetryTopic          string `mapstructure:"retry_topic"`
+	DeadLetterTopic     string `mapstructure:"dead_letter_topic"`
+	MaxRetries          int    `mapstructure:"max_retries"`
+	RetryBackoffSeconds int    `mapstructure:"retry_backoff_seconds"`
+}
+
+// AuthConfig 服务间调用鉴权配置
+type AuthConfig struct {
+	Enabled        bool `mapstructure:"enabled"`
+	MaxSkewSeconds int  `mapstructure:"max_skew_seconds"` // 请求时间戳允许的最大偏差，同时决定 nonce 的保留时间
+	CacheSeconds   int  `mapstructure:"cache_seconds"`    // 应用凭证本地缓存时间
+}
+
+// JWTConfig 终端用户令牌校验配置
+type JWTConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	HMACSecret    string `mapstructure:"hmac_secret"`    // HS256 密钥（令牌 header 不带 kid 时使用）
+	JWKSFile      string `mapstructure:"jwks_file"`      // 本地 JWKS 文件（RS256 公钥 / 带 kid 的 HS256 密钥）
+	Issuer        string `mapstructure:"issuer"`         // 为空不校验
+	Audience      string `mapstructure:"audience"`       // 为空不校验
+	LeewaySeconds int    `mapstructure:"leeway_seconds"` // 允许的时钟偏差
+}
+
+// AdminConfig 管理后台配置
+type AdminConfig struct {
+	Enabled               bool      `mapstructure:"enabled"`
+	JWT                   JWTConfig `mapstructure:"jwt"`                     // 管理后台令牌，sub 为管理员用户名
+	ApprovalThreshold     int64     `mapstructure:"approval_threshold"`      // 写操作金额超过该值需要另一位管理员审批
+	ExecuteTimeoutSeconds int       `mapstructure:"execute_timeout_seconds"` // 审批单执行中（APPROVED）超过该时长视为中断，允许重新执行，默认 600
+}
+
+// MetricsConfig Prometheus 指标配置
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"` // 指标暴露路径，默认 /metrics
+}
+
+// HealthConfig 健康检查配置
+type HealthConfig struct {
+	TimeoutMs            int      `mapstructure:"timeout_ms"`             // 单项检查超时，默认 2000
+	JobStaleFactor       int      `mapstructure:"job_stale_factor"`       // 后台任务超过 间隔 × 该倍数 没有心跳视为卡死，默认 3
+	OutboxMaxPending     int64    `mapstructure:"outbox_max_pending"`     // 待发送消息超过该数量视为异常，0 不检查
+	OutboxMaxAgeSeconds  int      `mapstructure:"outbox_max_age_seconds"` // 最早一条待发送消息等待超过该时长视为异常，0 不检查
+	ShutdownDelaySeconds int      `mapstructure:"shutdown_delay_seconds"` // 退出时先返回未就绪，等待该时长让负载均衡摘掉流量
+	Critical             []string `mapstructure:"critical"`               // 关键检查项，只有这些项失败才返回未就绪，其他项失败只标记为降级；为空时为 mysql、redis、kafka、outbox
+}
+
+// LeaderConfig 单实例任务的主节点选举配置
+type LeaderConfig struct {
+	TTLSeconds int    `mapstructure:"ttl_seconds"` // 租约时长，主节点崩溃后最多这么久由其他实例接管，默认 10
+	InstanceID string `mapstructure:"instance_id"` // 实例 ID，为空时使用 主机名-进程号
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Level      string   `mapstructure:"level"`       // debug | info | warn | error
+	Format     string   `mapstructure:"format"`      // json | text
+	RedactKeys []string `mapstructure:"redact_keys"` // 额外需要脱敏的字段名
+}
+
+// TracingConfig 链路追踪配置
+type TracingConfig struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	ServiceName string  `mapstructure:"service_name"`
+	Exporter    string  `mapstructure:"exporter"`     // otlp | stdout
+	Endpoint    string  `mapstructure:"endpoint"`     // OTLP/HTTP 地址，如 otel-collector:4318，为空时读 OTEL_EXPORTER_OTLP_ENDPOINT
+	Insecure    bool    `mapstructure:"insecure"`     // 不使用 TLS
+	SampleRatio float64 `mapstructure:"sample_ratio"` // 采样比例，上游已采样的请求总是采样
+}
+
+// IdempotencyConfig 接口幂等配置
+type IdempotencyConfig struct {
+	Enabled                  bool   `mapstructure:"enabled"`
+	Header                   string `mapstructure:"header"`                     // 幂等键请求头，默认 X-Request-ID
+	TTLHours                 int    `mapstructure:"ttl_hours"`                  // 响应保留时长
+	ProcessingTimeoutSeconds int    `mapstructure:"processing_timeout_seconds"` // 处理中记录的超时时间，超时后允许重新处理
+	WaitMilliseconds         int    `mapstructure:"wait_milliseconds"`          // 重复请求等待首个请求完成的最长时间
+}
+
+// RateLimitConfig 限流配置
+type RateLimitConfig struct {
+	Enabled bool                            `mapstructure:"enabled"`
+	Default RouteRateLimitConfig            `mapstructure:"default"` // 未单独配置的路由使用
+	Routes  map[string]RouteRateLimitConfig `mapstructure:"routes"`  // key 为路由，如 /api/v1/pay/execute
+}
+
+// RouteRateLimitConfig 单个路由的限流规则，按用户、按调用方应用分别计数
+type RouteRateLimitConfig struct {
+	User RateLimitRule `mapstructure:"user"`
+	App  RateLimitRule `mapstructure:"app"`
+}
+
+// RateLimitRule 令牌桶参数，Rate <= 0 表示不限流
+type RateLimitRule struct {
+	Rate  float64 `mapstructure:"rate"`  // 每秒补充的令牌数
+	Burst int     `mapstructure:"burst"` // 桶容量（允许的突发请求数）
 }
 
 type BusinessConfig struct {
@@ -51,6 +190,33 @@ type BusinessConfig struct {
 	MaxRetryCount       int `mapstructure:"max_retry_count"`
 }
 
+// 已发送消息的保留方式
+const (
+	OutboxRetentionArchive = "archive" // 移入归档表
+	OutboxRetentionDelete  = "delete"  // 直接删除
+	OutboxRetentionKeep    = "keep"    // 不清理
+)
+
+type OutboxConfig struct {
+	Retention OutboxRetentionConfig `mapstructure:"retention"`
+}
+
+// OutboxRetentionConfig 已发送消息的清理配置
+type OutboxRetentionConfig struct {
+	Enabled         bool                                  `mapstructure:"enabled"`
+	IntervalSeconds int                                   `mapstructure:"interval_seconds"`
+	BatchSize       int                                   `mapstructure:"batch_size"`
+	MaxBatches      int                                   `mapstructure:"max_batches"` // 每轮最多处理的批次数
+	Mode            string                                `mapstructure:"mode"`
+	RetentionHours  int                                   `mapstructure:"retention_hours"`
+	Topics          map[string]OutboxTopicRetentionConfig `mapstructure:"topics"` // 按主题覆盖默认配置
+}
+
+type OutboxTopicRetentionConfig struct {
+	Mode           string `mapstructure:"mode"`
+	RetentionHours int    `mapstructure:"retention_hours"`
+}
+
 var GlobalConfig *Config
 
 // LoadConfig 加载配置文件
@@ -0,0 +1,161 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+
+	"paysystem/internal/config"
+	"paysystem/internal/event"
+	"paysystem/internal/infrastructure/lock"
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/infrastructure/mq"
+	"paysystem/internal/model"
+	"paysystem/internal/repository"
+	"paysystem/internal/service"
+	"paysystem/pkg/errs"
+
+	"gorm.io/gorm"
+)
+
+// ============================================================================
+// 上游命令消费
+// ============================================================================
+//
+// 上游系统（如活动平台）通过 Kafka 下发命令，不再需要调用 HTTP 接口。
+//
+// 【如何保证不重复处理？】
+//
+// Kafka 是 at-least-once 投递，同一条命令可能被消费多次。
+// 每条命令处理时，在同一个数据库事务里：
+//   1. 写入 inbox_message（message_id 唯一索引）
+//   2. 执行业务逻辑
+// 事务提交后消息即视为已处理，再次投递时 inbox 写入冲突，直接跳过。
+//
+// ============================================================================
+
+var consumerLog = logging.For("CommandConsumer")
+
+type commandHandler func(ctx context.Context, tx *gorm.DB, cmd *event.Envelope) error
+
+type CommandConsumer struct {
+	db             *gorm.DB
+	inboxRepo      *repository.InboxRepository
+	accountService *service.AccountService
+	refundService  *service.RefundService
+	handlers       map[string]commandHandler
+}
+
+func NewCommandConsumer(db *gorm.DB, locker lock.Locker, cfg *config.Config) *CommandConsumer {
+	c := &CommandConsumer{
+		db:             db,
+		inboxRepo:      repository.NewInboxRepository(db),
+		accountService: service.NewAccountService(db, locker, cfg),
+		refundService:  service.NewRefundService(db, locker, cfg),
+	}
+	c.handlers = map[string]commandHandler{
+		event.TypeGrantCoinsCommand:  c.handleGrantCoins,
+		event.TypeRefundOrderCommand: c.handleRefundOrder,
+	}
+	return c
+}
+
+// Handle 处理一条命令消息，作为 mq.MessageHandler 注册到消费者
+func (c *CommandConsumer) Handle(ctx context.Context, msg *mq.Message) error {
+	cmd, err := event.Parse(msg.Value)
+	if err != nil {
+		return mq.NonRetryable(err)
+	}
+
+	handler, ok := c.handlers[cmd.Type]
+	if !ok {
+		return mq.NonRetryable(errors.New("未知命令类型: " + cmd.Type))
+	}
+
+	messageID := cmd.ID
+	if messageID == "" {
+		messageID = msg.ID()
+	}
+
+	processed, err := c.inboxRepo.Exists(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	if processed {
+		consumerLog.InfoContext(ctx, "命令已处理，跳过", "message_id", messageID, "type", cmd.Type)
+		return nil
+	}
+
+	err = c.db.Transaction(func(tx *gorm.DB) error {
+		inboxMsg := &model.InboxMessage{
+			MessageID:   messageID,
+			Topic:       msg.OriginalTopic,
+			CommandType: cmd.Type,
+		}
+		if err := c.inboxRepo.Create(ctx, tx, inboxMsg); err != nil {
+			return err
+		}
+		return handler(ctx, tx, cmd)
+	})
+
+	if errors.Is(err, repository.ErrDuplicateMessage) {
+		consumerLog.InfoContext(ctx, "命令已处理，跳过", "message_id", messageID, "type", cmd.Type)
+		return nil
+	}
+	if err != nil {
+		// 明确不可重试的业务错误（如订单状态不允许退款）重试也不会成功，直接进入死信
+		var bizErr *errs.Error
+		if errors.As(err, &bizErr) && !bizErr.Retryable {
+			return mq.NonRetryable(err)
+		}
+		return err
+	}
+
+	consumerLog.InfoContext(ctx, "命令处理成功", "message_id", messageID, "type", cmd.Type)
+	return nil
+}
+
+func (c *CommandConsumer) handleGrantCoins(ctx context.Context, tx *gorm.DB, cmd *event.Envelope) error {
+	var data event.GrantCoinsCommand
+	if err := cmd.DecodeData(&data); err != nil {
+		return mq.NonRetryable(err)
+	}
+	if data.UserID <= 0 || data.Amount <= 0 {
+		return mq.NonRetryable(errors.New("发放硬币参数错误"))
+	}
+
+	requestID := data.RequestID
+	if requestID == "" {
+		requestID = cmd.ID
+	}
+
+	return c.accountService.GrantCoins(ctx, tx, &service.GrantCoinsRequest{
+		RequestID: requestID,
+		UserID:    data.UserID,
+		Amount:    data.Amount,
+		Reason:    data.Reason,
+	})
+}
+
+// handleRefundOrder 退款自身带有事务和幂等校验，这里只复用 RefundService
+// 退款已提交但 inbox 事务失败时命令会重新投递，RefundService 按退款流水返回已退款，不会进入死信
+func (c *CommandConsumer) handleRefundOrder(ctx context.Context, _ *gorm.DB, cmd *event.Envelope) error {
+	var data event.RefundOrderCommand
+	if err := cmd.DecodeData(&data); err != nil {
+		return mq.NonRetryable(err)
+	}
+	if data.OrderNo == "" {
+		return mq.NonRetryable(errors.New("退款订单号为空"))
+	}
+
+	requestID := data.RequestID
+	if requestID == "" {
+		requestID = cmd.ID
+	}
+
+	_, err := c.refundService.Refund(ctx, &service.RefundRequest{
+		RequestID: requestID,
+		OrderNo:   data.OrderNo,
+		Reason:    data.Reason,
+	})
+	return err
+}
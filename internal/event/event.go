@@ -0,0 +1,285 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"paysystem/internal/infrastructure/tracing"
+	"paysystem/internal/model"
+	"paysystem/pkg/idgen"
+)
+
+// ============================================================================
+// 领域事件定义
+// ============================================================================
+//
+// 【为什么要有统一的事件结构？】
+//
+// 之前支付、退款的消息体都是临时拼出来的 map，而且都发到 pay_result 主题，
+// 消费方只能靠"有没有 refund_no 字段"来区分事件类型，字段一改就全挂。
+//
+// 现在所有事件都包在一个 CloudEvents 风格的信封里：
+//   - type:          事件类型，如 paysystem.order.paid
+//   - schemaversion: 事件体的版本号，字段有不兼容变更时递增
+//   - id:            事件唯一ID，消费方据此去重
+//   - time:          事件发生时间
+//   - data:          具体的事件体（强类型结构）
+//
+// 事件类型同时写入 Kafka 消息头（ce_type 等），消费方不用反序列化消息体就能路由。
+//
+// ============================================================================
+
+const (
+	SpecVersion = "1.0"
+	Source      = "paysystem"
+
+	ContentType = "application/cloudevents+json"
+)
+
+// 事件类型
+const (
+	TypeOrderPaid      = "paysystem.order.paid"
+	TypeOrderRefunded  = "paysystem.order.refunded"
+	TypeOrderClosed    = "paysystem.order.closed"
+	TypeOrderCancelled = "paysystem.order.cancelled"
+	TypeOrderFailed    = "paysystem.order.failed"
+
+	TypeAccountAdjusted = "paysystem.account.adjusted"
+)
+
+// 上游命令类型（命令与事件共用同一个信封结构）
+const (
+	TypeGrantCoinsCommand  = "paysystem.command.grant_coins"
+	TypeRefundOrderCommand = "paysystem.command.refund_order"
+)
+
+// 事件体版本号
+const (
+	OrderPaidSchemaVersion      = 1
+	OrderRefundedSchemaVersion  = 1
+	OrderClosedSchemaVersion    = 1
+	OrderCancelledSchemaVersion = 1
+	OrderFailedSchemaVersion    = 1
+
+	AccountAdjustedSchemaVersion = 1
+)
+
+// Kafka 消息头
+const (
+	HeaderSpecVersion   = "ce_specversion"
+	HeaderID            = "ce_id"
+	HeaderType          = "ce_type"
+	HeaderSource        = "ce_source"
+	HeaderTime          = "ce_time"
+	HeaderSchemaVersion = "ce_schemaversion"
+	HeaderContentType   = "content-type"
+)
+
+// Envelope 事件信封
+type Envelope struct {
+	SpecVersion   string      `json:"specversion"`
+	ID            string      `json:"id"`
+	Source        string      `json:"source"`
+	Type          string      `json:"type"`
+	SchemaVersion int         `json:"schemaversion"`
+	Subject       string      `json:"subject,omitempty"` // 事件主体，一般是订单号
+	Time          time.Time   `json:"time"`
+	Data          interface{} `json:"data"`
+}
+
+// OrderPaidData 支付成功事件体
+type OrderPaidData struct {
+	OrderNo     string    `json:"order_no"`
+	UserID      int64     `json:"user_id"`
+	Amount      int64     `json:"amount"`
+	ProductType string    `json:"product_type"`
+	ProductID   string    `json:"product_id"`
+	Status      string    `json:"status"`
+	PaidAt      time.Time `json:"paid_at"`
+}
+
+// OrderRefundedData 退款成功事件体
+type OrderRefundedData struct {
+	RefundNo   string    `json:"refund_no"`
+	OrderNo    string    `json:"order_no"`
+	UserID     int64     `json:"user_id"`
+	Amount     int64     `json:"amount"`
+	Status     string    `json:"status"`
+	Reason     string    `json:"reason"`
+	RefundedAt time.Time `json:"refunded_at"`
+}
+
+// OrderClosedData 订单超时关闭事件体
+type OrderClosedData struct {
+	OrderNo     string    `json:"order_no"`
+	UserID      int64     `json:"user_id"`
+	Amount      int64     `json:"amount"`
+	ProductType string    `json:"product_type"`
+	ProductID   string    `json:"product_id"`
+	Status      string    `json:"status"`
+	ExpiredAt   time.Time `json:"expired_at"`
+	ClosedAt    time.Time `json:"closed_at"`
+}
+
+// OrderCancelledData 订单取消事件体
+type OrderCancelledData struct {
+	OrderNo     string    `json:"order_no"`
+	UserID      int64     `json:"user_id"`
+	Amount      int64     `json:"amount"`
+	ProductType string    `json:"product_type"`
+	ProductID   string    `json:"product_id"`
+	Status      string    `json:"status"`
+	CancelledAt time.Time `json:"cancelled_at"`
+}
+
+// OrderFailedData 支付失败事件体
+type OrderFailedData struct {
+	OrderNo     string    `json:"order_no"`
+	UserID      int64     `json:"user_id"`
+	Amount      int64     `json:"amount"`
+	ProductType string    `json:"product_type"`
+	ProductID   string    `json:"product_id"`
+	Status      string    `json:"status"`
+	Reason      string    `json:"reason"`
+	FailedAt    time.Time `json:"failed_at"`
+}
+
+// AccountAdjustedData 人工调账事件体
+type AccountAdjustedData struct {
+	AdjustmentNo  string    `json:"adjustment_no"` // 调账单号（审批单号）
+	TransactionNo string    `json:"transaction_no"`
+	UserID        int64     `json:"user_id"`
+	Amount        int64     `json:"amount"` // 正数加款，负数扣款
+	BalanceBefore int64     `json:"balance_before"`
+	BalanceAfter  int64     `json:"balance_after"`
+	Reason        string    `json:"reason"`
+	RequestedBy   string    `json:"requested_by"`
+	ApprovedBy    string    `json:"approved_by"`
+	AdjustedAt    time.Time `json:"adjusted_at"`
+}
+
+// GrantCoinsCommand 发放硬币命令（如活动平台发放奖励）
+type GrantCoinsCommand struct {
+	RequestID string `json:"request_id"`
+	UserID    int64  `json:"user_id"`
+	Amount    int64  `json:"amount"`
+	Reason    string `json:"reason"`
+}
+
+// RefundOrderCommand 退款命令
+type RefundOrderCommand struct {
+	RequestID string `json:"request_id"`
+	OrderNo   string `json:"order_no"`
+	Reason    string `json:"reason"`
+}
+
+// New 创建事件
+func New(eventType string, schemaVersion int, subject string, occurredAt time.Time, data interface{}) *Envelope {
+	return &Envelope{
+		SpecVersion:   SpecVersion,
+		ID:            idgen.GenerateEventID(),
+		Source:        Source,
+		Type:          eventType,
+		SchemaVersion: schemaVersion,
+		Subject:       subject,
+		Time:          occurredAt,
+		Data:          data,
+	}
+}
+
+// NewOrderPaid 创建支付成功事件
+func NewOrderPaid(data *OrderPaidData) *Envelope {
+	return New(TypeOrderPaid, OrderPaidSchemaVersion, data.OrderNo, data.PaidAt, data)
+}
+
+// NewOrderRefunded 创建退款成功事件
+func NewOrderRefunded(data *OrderRefundedData) *Envelope {
+	return New(TypeOrderRefunded, OrderRefundedSchemaVersion, data.OrderNo, data.RefundedAt, data)
+}
+
+// NewOrderClosed 创建订单超时关闭事件
+func NewOrderClosed(data *OrderClosedData) *Envelope {
+	return New(TypeOrderClosed, OrderClosedSchemaVersion, data.OrderNo, data.ClosedAt, data)
+}
+
+// NewOrderCancelled 创建订单取消事件
+func NewOrderCancelled(data *OrderCancelledData) *Envelope {
+	return New(TypeOrderCancelled, OrderCancelledSchemaVersion, data.OrderNo, data.CancelledAt, data)
+}
+
+// NewOrderFailed 创建支付失败事件
+func NewOrderFailed(data *OrderFailedData) *Envelope {
+	return New(TypeOrderFailed, OrderFailedSchemaVersion, data.OrderNo, data.FailedAt, data)
+}
+
+// NewAccountAdjusted 创建人工调账事件，主体为用户ID
+func NewAccountAdjusted(data *AccountAdjustedData) *Envelope {
+	return New(TypeAccountAdjusted, AccountAdjustedSchemaVersion, strconv.FormatInt(data.UserID, 10), data.AdjustedAt, data)
+}
+
+// Headers 事件对应的 Kafka 消息头
+func (e *Envelope) Headers() map[string]string {
+	return map[string]string{
+		HeaderSpecVersion:   e.SpecVersion,
+		HeaderID:            e.ID,
+		HeaderType:          e.Type,
+		HeaderSource:        e.Source,
+		HeaderTime:          e.Time.Format(time.RFC3339Nano),
+		HeaderSchemaVersion: strconv.Itoa(e.SchemaVersion),
+		HeaderContentType:   ContentType,
+	}
+}
+
+// ToOutbox 将事件转换为 outbox 消息，由调用方在业务事务内写入
+// ctx 中的 trace 上下文一并写入消息头，异步发送到 Kafka 时继续同一条 trace
+func (e *Envelope) ToOutbox(ctx context.Context, topic, messageKey string) (*model.OutboxMessage, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	eventHeaders := e.Headers()
+	tracing.Inject(ctx, eventHeaders)
+	headers, err := json.Marshal(eventHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.OutboxMessage{
+		MessageKey: messageKey,
+		Topic:      topic,
+		EventType:  e.Type,
+		Headers:    string(headers),
+		Payload:    string(payload),
+		Status:     model.OutboxStatusPending,
+	}, nil
+}
+
+// Parse 解析消息体，Data 保留为原始 JSON，由调用方按事件类型调用 DecodeData 解析
+func Parse(payload []byte) (*Envelope, error) {
+	var raw struct {
+		Envelope
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, err
+	}
+	if raw.Type == "" {
+		return nil, errors.New("事件类型为空")
+	}
+
+	e := raw.Envelope
+	e.Data = raw.Data
+	return &e, nil
+}
+
+// DecodeData 将事件体解析到 v
+func (e *Envelope) DecodeData(v interface{}) error {
+	data, ok := e.Data.(json.RawMessage)
+	if !ok {
+		return errors.New("事件体不是原始 JSON")
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,344 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/election"
+	"paysystem/internal/infrastructure/lock"
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/model"
+	"paysystem/internal/repository"
+	"paysystem/internal/service"
+	"paysystem/pkg/errs"
+	"paysystem/pkg/jwt"
+	"paysystem/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// ============================================================================
+// 管理后台接口 /admin/v1
+// ============================================================================
+//
+// 鉴权：管理后台 JWT（audience 与用户令牌不同），sub 为管理员用户名，角色以 admin_user 表为准。
+// 权限：见 model.AdminRolePermissions，每个路由声明需要的权限。
+// 写操作：金额超过阈值的生成审批单，所有写操作记审计日志（见 service.AdminService）。
+//
+// ============================================================================
+
+var adminLog = logging.For("Admin")
+
+const ctxKeyAdmin = "admin_user"
+
+// AdminHandler 管理后台处理器
+type AdminHandler struct {
+	adminService *service.AdminService
+}
+
+func NewAdminHandler(db *gorm.DB, rdb *redis.Client, locker lock.Locker, cfg *config.Config) *AdminHandler {
+	return &AdminHandler{
+		adminService: service.NewAdminService(db, rdb, locker, cfg),
+	}
+}
+
+// AuthMiddleware 校验管理后台令牌并加载管理员
+func (h *AdminHandler) AuthMiddleware(cfg *config.JWTConfig) (gin.HandlerFunc, error) {
+	verifier, err := jwt.NewVerifier(jwt.Options{
+		HMACSecret: cfg.HMACSecret,
+		JWKSFile:   cfg.JWKSFile,
+		Issuer:     cfg.Issuer,
+		Audience:   cfg.Audience,
+		Leeway:     time.Duration(cfg.LeewaySeconds) * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("初始化管理后台 JWT 校验失败: %w", err)
+	}
+
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c)
+		if !ok {
+			response.Unauthorized(c, "admin.missing_token")
+			c.Abort()
+			return
+		}
+		claims, err := verifier.Verify(token)
+		if err != nil {
+			response.Unauthorized(c, "admin.invalid_token", response.T(c, tokenErrorKey(err)))
+			c.Abort()
+			return
+		}
+
+		admin, err := h.adminService.GetAdminUser(c.Request.Context(), claims.Subject)
+		if err != nil {
+			if errors.Is(err, repository.ErrAdminNotFound) {
+				response.Unauthorized(c, "admin.not_found")
+			} else {
+				respondError(c, err)
+			}
+			c.Abort()
+			return
+		}
+		if admin.Status != model.AdminStatusActive {
+			response.Unauthorized(c, "admin.disabled")
+			c.Abort()
+			return
+		}
+
+		c.Set(ctxKeyAdmin, admin)
+		c.Request = c.Request.WithContext(logging.WithFields(c.Request.Context(), "admin", admin.Username))
+		c.Next()
+	}, nil
+}
+
+// RequirePermission 要求当前管理员拥有指定权限
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		admin := c.MustGet(ctxKeyAdmin).(*model.AdminUser)
+		if !model.AdminHasPermission(admin.Role, perm) {
+			adminLog.WarnContext(c.Request.Context(), "权限不足", "admin", admin.Username, "role", admin.Role, "perm", perm)
+			response.Forbidden(c, "admin.permission_required", perm)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func currentOperator(c *gin.Context) *service.AdminOperator {
+	admin := c.MustGet(ctxKeyAdmin).(*model.AdminUser)
+	return &service.AdminOperator{
+		Username: admin.Username,
+		Role:     admin.Role,
+		ClientIP: c.ClientIP(),
+	}
+}
+
+func pagination(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
+
+// SearchOrders 订单搜索
+// GET /admin/v1/orders?order_no=&request_id=&user_id=&status=&start_time=&end_time=&page=&page_size=
+func (h *AdminHandler) SearchOrders(c *gin.Context) {
+	filter := &repository.OrderSearchFilter{
+		OrderNo:   c.Query("order_no"),
+		RequestID: c.Query("request_id"),
+		Status:    strings.ToUpper(c.Query("status")),
+	}
+	if v := c.Query("user_id"); v != "" {
+		userID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondError(c, errs.ErrInvalidParam.WithDetail("user_id"))
+			return
+		}
+		filter.UserID = userID
+	}
+	for name, target := range map[string]**time.Time{"start_time": &filter.StartTime, "end_time": &filter.EndTime} {
+		if v := c.Query(name); v != "" {
+			t, err := time.ParseInLocation("2006-01-02 15:04:05", v, time.Local)
+			if err != nil {
+				respondError(c, errs.ErrInvalidParam.WithDetail("%s (2006-01-02 15:04:05)", name))
+				return
+			}
+			*target = &t
+		}
+	}
+
+	page, pageSize := pagination(c)
+	orders, total, err := h.adminService.SearchOrders(c.Request.Context(), filter, page, pageSize)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"list": orders, "total": total, "page": page, "page_size": pageSize})
+}
+
+// RefundOrder 人工退款
+// POST /admin/v1/orders/refund
+func (h *AdminHandler) RefundOrder(c *gin.Context) {
+	var req struct {
+		OrderNo string `json:"order_no" binding:"required"`
+		Reason  string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	result, err := h.adminService.Refund(c.Request.Context(), currentOperator(c), &service.AdminRefundPayload{
+		OrderNo: req.OrderNo,
+		Reason:  req.Reason,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	localizeResult(c, result)
+	response.Success(c, result)
+}
+
+// AdjustBalance 人工调账
+// POST /admin/v1/accounts/adjust
+func (h *AdminHandler) AdjustBalance(c *gin.Context) {
+	var req struct {
+		UserID int64  `json:"user_id" binding:"required"`
+		Amount int64  `json:"amount" binding:"required"`
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	result, err := h.adminService.AdjustBalance(c.Request.Context(), currentOperator(c), &service.AdminAdjustPayload{
+		UserID: req.UserID,
+		Amount: req.Amount,
+		Reason: req.Reason,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, result)
+}
+
+// FreezeAccount 冻结/解冻账户
+// POST /admin/v1/accounts/freeze
+func (h *AdminHandler) FreezeAccount(c *gin.Context) {
+	var req struct {
+		UserID int64  `json:"user_id" binding:"required"`
+		Frozen *bool  `json:"frozen" binding:"required"`
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	result, err := h.adminService.FreezeAccount(c.Request.Context(), currentOperator(c), &service.AdminFreezePayload{
+		UserID: req.UserID,
+		Frozen: *req.Frozen,
+		Reason: req.Reason,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, result)
+}
+
+// ReplayOutbox 重新投递 outbox 消息
+// POST /admin/v1/outbox/replay
+func (h *AdminHandler) ReplayOutbox(c *gin.Context) {
+	var req struct {
+		IDs    []int64 `json:"ids" binding:"required,min=1,max=500"`
+		Reason string  `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	result, err := h.adminService.ReplayOutbox(c.Request.Context(), currentOperator(c), &service.AdminOutboxReplayPayload{
+		IDs:    req.IDs,
+		Reason: req.Reason,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, result)
+}
+
+// ListLeaders 单实例后台任务当前的主节点
+// GET /admin/v1/leaders
+func (h *AdminHandler) ListLeaders(c *gin.Context) {
+	statuses, err := election.Statuses(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"list": statuses})
+}
+
+// ListApprovals 审批单列表
+// GET /admin/v1/approvals?status=PENDING&page=&page_size=
+func (h *AdminHandler) ListApprovals(c *gin.Context) {
+	page, pageSize := pagination(c)
+	approvals, total, err := h.adminService.ListApprovals(c.Request.Context(), strings.ToUpper(c.Query("status")), page, pageSize)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"list": approvals, "total": total, "page": page, "page_size": pageSize})
+}
+
+type reviewRequest struct {
+	ApprovalNo string `json:"approval_no" binding:"required"`
+	Note       string `json:"note"`
+}
+
+// ApproveApproval 审批通过并执行
+// POST /admin/v1/approvals/approve
+func (h *AdminHandler) ApproveApproval(c *gin.Context) {
+	var req reviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	result, err := h.adminService.Approve(c.Request.Context(), currentOperator(c), req.ApprovalNo, req.Note)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	localizeResult(c, result)
+	response.Success(c, result)
+}
+
+// RejectApproval 驳回
+// POST /admin/v1/approvals/reject
+func (h *AdminHandler) RejectApproval(c *gin.Context) {
+	var req reviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindError(err))
+		return
+	}
+
+	if err := h.adminService.Reject(c.Request.Context(), currentOperator(c), req.ApprovalNo, req.Note); err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": response.T(c, "admin.approval_rejected")})
+}
+
+// ListAuditLogs 审计日志
+// GET /admin/v1/audit-logs?operator=&action=&target=&page=&page_size=
+func (h *AdminHandler) ListAuditLogs(c *gin.Context) {
+	page, pageSize := pagination(c)
+	logs, total, err := h.adminService.ListAuditLogs(c.Request.Context(), &repository.AuditLogFilter{
+		Operator: c.Query("operator"),
+		Action:   c.Query("action"),
+		Target:   c.Query("target"),
+	}, page, pageSize)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"list": logs, "total": total, "page": page, "page_size": pageSize})
+}
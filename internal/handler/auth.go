@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/model"
+	"paysystem/internal/repository"
+	"paysystem/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// ============================================================================
+// 服务间调用鉴权（HMAC 签名）
+// ============================================================================
+//
+// 调用方在 app_credential 表中登记 app_id / secret / scopes，每个请求带上：
+//
+//   X-App-ID      应用ID
+//   X-Timestamp   Unix 时间戳（秒）
+//   X-Nonce       随机串，每个请求不同
+//   X-Signature   hex(HMAC-SHA256(secret, 待签名串))
+//
+// 待签名串（换行分隔）：
+//
+//   METHOD \n PATH \n RAW_QUERY \n TIMESTAMP \n NONCE \n hex(SHA256(body))
+//
+// 【防重放】
+//   - 时间戳与服务器时间相差超过 max_skew 的请求直接拒绝
+//   - nonce 在 Redis 中保留 2 * max_skew，时间窗口内同一个 nonce 只能使用一次
+//
+// 签名校验失败返回 401（CodeUnauthorized），权限不足返回 403（CodeForbidden）。
+//
+// ============================================================================
+
+var authLog = logging.For("Auth")
+
+const (
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+
+	ctxKeyCallerApp = "caller_app"
+	authNoncePrefix = "pay:auth:nonce:"
+)
+
+// AppAuthenticator 校验调用方签名
+type AppAuthenticator struct {
+	rdb      *redis.Client
+	appRepo  *repository.AppRepository
+	maxSkew  time.Duration
+	cacheTTL time.Duration
+
+	mu    sync.RWMutex
+	cache map[string]cachedApp
+}
+
+type cachedApp struct {
+	app      *model.AppCredential
+	expireAt time.Time
+}
+
+func NewAppAuthenticator(db *gorm.DB, rdb *redis.Client, cfg *config.AuthConfig) *AppAuthenticator {
+	maxSkew := time.Duration(cfg.MaxSkewSeconds) * time.Second
+	if maxSkew <= 0 {
+		maxSkew = 5 * time.Minute
+	}
+	return &AppAuthenticator{
+		rdb:      rdb,
+		appRepo:  repository.NewAppRepository(db),
+		maxSkew:  maxSkew,
+		cacheTTL: time.Duration(cfg.CacheSeconds) * time.Second,
+		cache:    make(map[string]cachedApp),
+	}
+}
+
+// SignRequest 计算请求签名，调用方 SDK 使用同样的算法
+func SignRequest(secret, method, path, rawQuery, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	payload := strings.Join([]string{
+		method, path, rawQuery, timestamp, nonce, hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Middleware 校验签名，通过后把调用方应用放入上下文
+func (a *AppAuthenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appID := c.GetHeader(AppIDHeader)
+		timestamp := c.GetHeader(HeaderTimestamp)
+		nonce := c.GetHeader(HeaderNonce)
+		signature := c.GetHeader(HeaderSignature)
+		if appID == "" || timestamp == "" || nonce == "" || signature == "" {
+			abortUnauthorized(c, "auth.missing_signature")
+			return
+		}
+
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			abortUnauthorized(c, "auth.invalid_timestamp")
+			return
+		}
+		skew := time.Since(time.Unix(ts, 0))
+		if skew > a.maxSkew || skew < -a.maxSkew {
+			abortUnauthorized(c, "auth.request_expired")
+			return
+		}
+
+		app, err := a.getApp(c, appID)
+		if err != nil {
+			if errors.Is(err, repository.ErrAppNotFound) {
+				abortUnauthorized(c, "auth.app_not_found")
+				return
+			}
+			authLog.ErrorContext(c.Request.Context(), "查询应用失败", "app_id", appID, "err", err)
+			response.ServerError(c, "auth.failed")
+			c.Abort()
+			return
+		}
+		if app.Status != model.AppStatusActive {
+			abortUnauthorized(c, "auth.app_disabled")
+			return
+		}
+
+		body, err := peekBody(c)
+		if err != nil {
+			response.ParamError(c, "common.read_body_failed")
+			c.Abort()
+			return
+		}
+		expected := SignRequest(app.Secret, c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, timestamp, nonce, body)
+		if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+			abortUnauthorized(c, "auth.invalid_signature")
+			return
+		}
+
+		// 签名通过后再记录 nonce，避免伪造请求占用合法调用方的 nonce
+		fresh, err := a.rdb.SetNX(c.Request.Context(), authNoncePrefix+appID+":"+nonce, 1, 2*a.maxSkew).Result()
+		if err != nil {
+			// 无法防重放时拒绝请求
+			authLog.ErrorContext(c.Request.Context(), "记录 nonce 失败", "app_id", appID, "err", err)
+			response.ServerError(c, "auth.failed")
+			c.Abort()
+			return
+		}
+		if !fresh {
+			abortUnauthorized(c, "auth.replayed_request")
+			return
+		}
+
+		c.Set(ctxKeyCallerApp, app)
+		c.Request = c.Request.WithContext(logging.WithFields(c.Request.Context(), "app_id", app.AppID))
+		c.Next()
+	}
+}
+
+// getApp 查询应用凭证，带本地缓存
+func (a *AppAuthenticator) getApp(c *gin.Context, appID string) (*model.AppCredential, error) {
+	if a.cacheTTL > 0 {
+		a.mu.RLock()
+		cached, ok := a.cache[appID]
+		a.mu.RUnlock()
+		if ok && time.Now().Before(cached.expireAt) {
+			return cached.app, nil
+		}
+	}
+
+	app, err := a.appRepo.GetByAppID(c.Request.Context(), appID)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.cacheTTL > 0 {
+		a.mu.Lock()
+		a.cache[appID] = cachedApp{app: app, expireAt: time.Now().Add(a.cacheTTL)}
+		a.mu.Unlock()
+	}
+	return app, nil
+}
+
+// RequireScope 要求调用方拥有指定权限，未开启鉴权（没有调用方信息）时放行
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get(ctxKeyCallerApp)
+		if !exists {
+			c.Next()
+			return
+		}
+		app := value.(*model.AppCredential)
+		if !app.HasScope(scope) {
+			authLog.WarnContext(c.Request.Context(), "权限不足", "app_id", app.AppID, "scope", scope, "path", c.FullPath())
+			response.Forbidden(c, "auth.scope_required", scope)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CallerAppID 当前请求的调用方应用ID，未鉴权时为空
+func CallerAppID(c *gin.Context) string {
+	if value, exists := c.Get(ctxKeyCallerApp); exists {
+		return value.(*model.AppCredential).AppID
+	}
+	return ""
+}
+
+func abortUnauthorized(c *gin.Context, message string) {
+	authLog.WarnContext(c.Request.Context(), "鉴权失败", "app_id", c.GetHeader(AppIDHeader), "path", c.Request.URL.Path, "reason", message)
+	response.Unauthorized(c, message)
+	c.Abort()
+}
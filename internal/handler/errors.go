@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/service"
+	"paysystem/pkg/errs"
+	"paysystem/pkg/i18n"
+	"paysystem/pkg/jwt"
+	"paysystem/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+var handlerLog = logging.For("Handler")
+
+// respondError 统一把错误转换成响应：业务码、HTTP 状态码、是否可重试都由错误类型决定，
+// 文案按错误的消息键取当前请求语言的版本，
+// 未定义类型的错误按内部错误处理，原因只打日志，不返回给客户端
+func respondError(c *gin.Context, err error) {
+	e := errs.From(err)
+	if e.HTTPStatus >= http.StatusInternalServerError {
+		handlerLog.ErrorContext(c.Request.Context(), "请求处理失败", "method", c.Request.Method, "path", c.Request.URL.Path, "err", err)
+	}
+
+	message, ok := i18n.Lookup(response.Locale(c), e.Key)
+	if !ok {
+		message = e.Message
+	}
+	if e.Detail != "" {
+		message += ": " + e.Detail
+	}
+	if e.Retryable && e.HTTPStatus == http.StatusServiceUnavailable {
+		c.Header("Retry-After", "1")
+	}
+	response.Fail(c, e.HTTPStatus, e.Code, message, e.Retryable)
+}
+
+// localizeResult 服务层结果里的提示是消息键，返回前翻译成当前请求的语言
+func localizeResult(c *gin.Context, result interface{}) {
+	switch r := result.(type) {
+	case *service.PayResponse:
+		r.Message = response.T(c, r.Message)
+	case *service.RefundResponse:
+		r.Message = response.T(c, r.Message)
+	case *service.AdminActionResult:
+		localizeResult(c, r.Data)
+	}
+}
+
+// tokenErrorKey 令牌校验失败原因对应的消息键
+func tokenErrorKey(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrUnsupportedAlg):
+		return "jwt.unsupported_alg"
+	case errors.Is(err, jwt.ErrKeyNotFound):
+		return "jwt.key_not_found"
+	case errors.Is(err, jwt.ErrInvalidSignature):
+		return "jwt.invalid_signature"
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "jwt.expired"
+	case errors.Is(err, jwt.ErrTokenNotYetValid):
+		return "jwt.not_yet_valid"
+	case errors.Is(err, jwt.ErrInvalidIssuer):
+		return "jwt.invalid_issuer"
+	case errors.Is(err, jwt.ErrInvalidAudience):
+		return "jwt.invalid_audience"
+	}
+	return "jwt.malformed"
+}
+
+// bindError 请求参数绑定/校验失败
+func bindError(err error) error {
+	var validationErrs validator.ValidationErrors
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := ""
+		for i, fe := range validationErrs {
+			if i > 0 {
+				fields += ", "
+			}
+			fields += fe.Field() + "(" + fe.Tag() + ")"
+		}
+		return errs.ErrInvalidParam.WithDetail("%s", fields)
+	case errors.As(err, &syntaxErr):
+		return errs.ErrInvalidParam.WithDetail("body")
+	case errors.As(err, &typeErr):
+		return errs.ErrInvalidParam.WithDetail("%s", typeErr.Field)
+	}
+	return errs.ErrInvalidParam.WithDetail("%s", err.Error())
+}
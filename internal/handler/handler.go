@@ -4,7 +4,9 @@ import (
 	"strconv"
 
 	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/lock"
 	"paysystem/internal/service"
+	"paysystem/pkg/errs"
 	"paysystem/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -21,12 +23,12 @@ type Handler struct {
 }
 
 // NewHandler 创建处理器实例
-func NewHandler(db *gorm.DB, rdb *redis.Client, cfg *config.Config) *Handler {
+func NewHandler(db *gorm.DB, rdb *redis.Client, locker lock.Locker, cfg *config.Config) *Handler {
 	return &Handler{
-		accountService: service.NewAccountService(db),
-		orderService:   service.NewOrderService(db, cfg),
-		payService:     service.NewPayService(db, rdb, cfg),
-		refundService:  service.NewRefundService(db, rdb, cfg),
+		accountService: service.NewAccountService(db, locker, cfg),
+		orderService:   service.NewOrderService(db, rdb, cfg),
+		payService:     service.NewPayService(db, locker, cfg),
+		refundService:  service.NewRefundService(db, locker, cfg),
 	}
 }
 
@@ -35,18 +37,20 @@ func NewHandler(db *gorm.DB, rdb *redis.Client, cfg *config.Config) *Handler {
 // ============================================================
 
 // GetBalance 查询用户余额
-// GET /api/v1/account/balance?user_id=xxx
+// GET /api/v1/account/balance?user_id=xxx（带用户令牌时 user_id 可省略）
 func (h *Handler) GetBalance(c *gin.Context) {
-	userIDStr := c.Query("user_id")
-	userID, err := strconv.ParseInt(userIDStr, 10, 64)
-	if err != nil {
-		response.ParamError(c, "user_id 参数错误")
+	userID, ok := resolveUserID(c)
+	if !ok {
+		respondError(c, errs.ErrInvalidParam.WithDetail("user_id"))
+		return
+	}
+	if !checkOwner(c, userID) {
 		return
 	}
 
 	account, err := h.accountService.GetAccount(c.Request.Context(), userID)
 	if err != nil {
-		response.ServerError(c, err.Error())
+		respondError(c, err)
 		return
 	}
 
@@ -68,17 +72,20 @@ type RechargeRequest struct {
 func (h *Handler) Recharge(c *gin.Context) {
 	var req RechargeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.ParamError(c, "参数错误: "+err.Error())
+		respondError(c, bindError(err))
+		return
+	}
+	if !checkOwner(c, req.UserID) {
 		return
 	}
 
 	if err := h.accountService.Recharge(c.Request.Context(), req.UserID, req.Amount); err != nil {
-		response.ServerError(c, err.Error())
+		respondError(c, err)
 		return
 	}
 
 	response.Success(c, gin.H{
-		"message": "充值成功",
+		"message": response.T(c, "account.recharge_success"),
 	})
 }
 
@@ -100,7 +107,10 @@ type CreateOrderRequest struct {
 func (h *Handler) CreateOrder(c *gin.Context) {
 	var req CreateOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.ParamError(c, "参数错误: "+err.Error())
+		respondError(c, bindError(err))
+		return
+	}
+	if !checkOwner(c, req.UserID) {
 		return
 	}
 
@@ -114,7 +124,7 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 
 	order, err := h.orderService.CreateOrder(c.Request.Context(), serviceReq)
 	if err != nil {
-		response.ServerError(c, err.Error())
+		respondError(c, err)
 		return
 	}
 
@@ -130,26 +140,41 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 func (h *Handler) GetOrder(c *gin.Context) {
 	orderNo := c.Query("order_no")
 	if orderNo == "" {
-		response.ParamError(c, "order_no 参数不能为空")
+		respondError(c, errs.ErrInvalidParam.WithDetail("order_no"))
 		return
 	}
 
 	order, err := h.orderService.GetOrder(c.Request.Context(), orderNo)
 	if err != nil {
-		response.ServerError(c, err.Error())
+		respondError(c, err)
+		return
+	}
+	if !checkOwner(c, order.UserID) {
 		return
 	}
 
 	response.Success(c, order)
 }
 
+// checkOrderOwner 校验当前用户是否为订单所有者，订单不存在或不是自己的订单时写入错误响应
+func (h *Handler) checkOrderOwner(c *gin.Context, orderNo string) bool {
+	order, err := h.orderService.GetOrder(c.Request.Context(), orderNo)
+	if err != nil {
+		respondError(c, err)
+		return false
+	}
+	return checkOwner(c, order.UserID)
+}
+
 // ListOrders 查询用户订单列表
-// GET /api/v1/order/list?user_id=xxx&page=1&page_size=10
+// GET /api/v1/order/list?user_id=xxx&page=1&page_size=10（带用户令牌时 user_id 可省略）
 func (h *Handler) ListOrders(c *gin.Context) {
-	userIDStr := c.Query("user_id")
-	userID, err := strconv.ParseInt(userIDStr, 10, 64)
-	if err != nil {
-		response.ParamError(c, "user_id 参数错误")
+	userID, ok := resolveUserID(c)
+	if !ok {
+		respondError(c, errs.ErrInvalidParam.WithDetail("user_id"))
+		return
+	}
+	if !checkOwner(c, userID) {
 		return
 	}
 
@@ -158,7 +183,7 @@ func (h *Handler) ListOrders(c *gin.Context) {
 
 	orders, total, err := h.orderService.ListUserOrders(c.Request.Context(), userID, page, pageSize)
 	if err != nil {
-		response.ServerError(c, err.Error())
+		respondError(c, err)
 		return
 	}
 
@@ -177,17 +202,20 @@ func (h *Handler) CancelOrder(c *gin.Context) {
 		OrderNo string `json:"order_no" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.ParamError(c, "参数错误: "+err.Error())
+		respondError(c, bindError(err))
+		return
+	}
+	if !h.checkOrderOwner(c, req.OrderNo) {
 		return
 	}
 
 	if err := h.orderService.CancelOrder(c.Request.Context(), req.OrderNo); err != nil {
-		response.ServerError(c, err.Error())
+		respondError(c, err)
 		return
 	}
 
 	response.Success(c, gin.H{
-		"message": "订单已取消",
+		"message": response.T(c, "order.cancelled"),
 	})
 }
 
@@ -214,7 +242,10 @@ type PayOrderRequest struct {
 func (h *Handler) PayOrder(c *gin.Context) {
 	var req PayOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.ParamError(c, "参数错误: "+err.Error())
+		respondError(c, bindError(err))
+		return
+	}
+	if !checkOwner(c, req.UserID) {
 		return
 	}
 
@@ -228,10 +259,11 @@ func (h *Handler) PayOrder(c *gin.Context) {
 
 	result, err := h.payService.Pay(c.Request.Context(), payReq)
 	if err != nil {
-		response.ServerError(c, err.Error())
+		respondError(c, err)
 		return
 	}
 
+	localizeResult(c, result)
 	response.Success(c, result)
 }
 
@@ -256,7 +288,10 @@ type RefundOrderRequest struct {
 func (h *Handler) RefundOrder(c *gin.Context) {
 	var req RefundOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.ParamError(c, "参数错误: "+err.Error())
+		respondError(c, bindError(err))
+		return
+	}
+	if !h.checkOrderOwner(c, req.OrderNo) {
 		return
 	}
 
@@ -268,9 +303,10 @@ func (h *Handler) RefundOrder(c *gin.Context) {
 
 	result, err := h.refundService.Refund(c.Request.Context(), refundReq)
 	if err != nil {
-		response.ServerError(c, err.Error())
+		respondError(c, err)
 		return
 	}
 
+	localizeResult(c, result)
 	response.Success(c, result)
 }
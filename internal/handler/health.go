@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"net/http"
+
+	"paysystem/internal/infrastructure/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LiveCheck 存活检查：进程能处理请求即返回 200，不检查依赖
+// GET /health/live
+func LiveCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// ReadyCheck 就绪检查：逐项检查依赖，关键项失败或正在关闭时返回 503，其他项失败返回 200 并标记为降级
+// GET /health/ready
+func ReadyCheck(c *gin.Context) {
+	report := health.Check(c.Request.Context())
+	if !report.Ready() {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
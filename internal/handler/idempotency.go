@@ -0,0 +1,329 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/model"
+	"paysystem/internal/repository"
+	"paysystem/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// ============================================================================
+// 接口幂等中间件
+// ============================================================================
+//
+// 客户端在请求头（默认 X-Request-ID）中带上幂等键，中间件以 调用方 + 用户 + 幂等键 + 方法 + 路由 为 key
+// （用户取用户令牌中的用户ID，不同用户用了同一个幂等键也不会拿到别人的响应）：
+//
+//   首次请求：占用 key（处理中）-> 执行业务 -> 保存响应（已完成）
+//   重复请求：
+//     - 请求体摘要不同       -> 409，同一个幂等键不能用于不同的请求
+//     - 首个请求已完成       -> 直接返回保存的响应（响应头 Idempotent-Replayed: true）
+//     - 首个请求仍在处理中   -> 等待最多 wait 时间，仍未完成返回 409（处理中），客户端稍后重试
+//
+// 首个请求失败（服务端错误、可重试的错误、鉴权失败或 panic）时释放 key，允许客户端用同一个幂等键重试。
+// 进程崩溃导致 key 一直处于处理中时，超过 processing_timeout 后可被重新占用。
+//
+// 【存储】优先使用 Redis，Redis 不可用时降级到 MySQL 的 idempotency_record 表。
+//
+// 【注意】中间件只是第一道防线，支付、退款等服务内部仍按 request_id 做幂等校验，
+// 不带幂等键的请求、或存储都不可用时（放行）依然不会重复扣款。
+//
+// ============================================================================
+
+var idemLog = logging.For("Idempotency")
+
+const (
+	idempotencyKeyPrefix      = "pay:idem:"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	idempotencyPollInterval   = 100 * time.Millisecond
+)
+
+// IdempotencyStore 幂等记录存储（Redis 优先，MySQL 兜底）
+type IdempotencyStore struct {
+	rdb  *redis.Client
+	repo *repository.IdempotencyRepository
+}
+
+func NewIdempotencyStore(rdb *redis.Client, db *gorm.DB) *IdempotencyStore {
+	return &IdempotencyStore{
+		rdb:  rdb,
+		repo: repository.NewIdempotencyRepository(db),
+	}
+}
+
+// releaseIdempotencyScript 只删除自己占用的处理中记录
+var releaseIdempotencyScript = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	end
+	return 0
+`)
+
+// Begin 占用幂等键，占用失败时返回已有的记录
+func (s *IdempotencyStore) Begin(ctx context.Context, record *model.IdempotencyRecord, timeout time.Duration) (bool, *model.IdempotencyRecord, error) {
+	record.Status = model.IdempotencyStatusProcessing
+	record.ExpiresAt = time.Now().Add(timeout)
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return false, nil, err
+	}
+
+	ok, err := s.rdb.SetNX(ctx, idempotencyKeyPrefix+record.Key, value, timeout).Result()
+	if err == nil {
+		if ok {
+			return true, nil, nil
+		}
+		existing, err := s.Get(ctx, record.Key)
+		if err != nil {
+			return false, nil, err
+		}
+		if existing == nil {
+			// 刚好过期，重新占用
+			return s.Begin(ctx, record, timeout)
+		}
+		return false, existing, nil
+	}
+
+	idemLog.WarnContext(ctx, "Redis 不可用，降级到数据库", "key", record.Key, "err", err)
+	ok, err = s.repo.Begin(ctx, record)
+	if err != nil || ok {
+		return ok, nil, err
+	}
+	existing, err := s.repo.GetByKey(ctx, record.Key)
+	return false, existing, err
+}
+
+// Get 查询幂等记录，不存在返回 nil
+func (s *IdempotencyStore) Get(ctx context.Context, key string) (*model.IdempotencyRecord, error) {
+	value, err := s.rdb.Get(ctx, idempotencyKeyPrefix+key).Bytes()
+	if err == nil {
+		var record model.IdempotencyRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			return nil, err
+		}
+		return &record, nil
+	}
+	if errors.Is(err, redis.Nil) {
+		// Redis 中没有，可能是 Redis 故障期间写入了数据库
+		return s.getFromDB(ctx, key)
+	}
+
+	idemLog.WarnContext(ctx, "Redis 不可用，降级到数据库", "key", key, "err", err)
+	return s.getFromDB(ctx, key)
+}
+
+func (s *IdempotencyStore) getFromDB(ctx context.Context, key string) (*model.IdempotencyRecord, error) {
+	record, err := s.repo.GetByKey(ctx, key)
+	if err != nil || record == nil {
+		return nil, err
+	}
+	if record.ExpiresAt.Before(time.Now()) {
+		return nil, nil
+	}
+	return record, nil
+}
+
+// Complete 保存响应
+func (s *IdempotencyStore) Complete(ctx context.Context, record *model.IdempotencyRecord, ttl time.Duration) error {
+	record.Status = model.IdempotencyStatusCompleted
+	record.ExpiresAt = time.Now().Add(ttl)
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if err := s.rdb.Set(ctx, idempotencyKeyPrefix+record.Key, value, ttl).Err(); err != nil {
+		idemLog.WarnContext(ctx, "Redis 不可用，降级到数据库", "key", record.Key, "err", err)
+		return s.repo.Complete(ctx, record)
+	}
+	// 占用时可能写的是数据库（Redis 当时不可用），同步更新，避免数据库里残留处理中记录
+	return s.repo.Complete(ctx, record)
+}
+
+// Release 释放处理中的幂等键
+func (s *IdempotencyStore) Release(ctx context.Context, record *model.IdempotencyRecord, processingValue []byte) {
+	if err := releaseIdempotencyScript.Run(ctx, s.rdb, []string{idempotencyKeyPrefix + record.Key}, processingValue).Err(); err != nil {
+		idemLog.ErrorContext(ctx, "Redis 释放幂等键失败", "key", record.Key, "err", err)
+	}
+	if err := s.repo.Delete(ctx, record.Key, record.RequestHash); err != nil {
+		idemLog.ErrorContext(ctx, "数据库释放幂等键失败", "key", record.Key, "err", err)
+	}
+}
+
+// responseRecorder 记录响应内容，用于保存并回放
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware 接口幂等中间件，只处理带幂等键的写请求
+func IdempotencyMiddleware(store *IdempotencyStore, cfg *config.IdempotencyConfig) gin.HandlerFunc {
+	header := cfg.Header
+	if header == "" {
+		header = "X-Request-ID"
+	}
+	ttl := time.Duration(cfg.TTLHours) * time.Hour
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	processingTimeout := time.Duration(cfg.ProcessingTimeoutSeconds) * time.Second
+	if processingTimeout <= 0 {
+		processingTimeout = time.Minute
+	}
+	maxWait := time.Duration(cfg.WaitMilliseconds) * time.Millisecond
+
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+			c.Next()
+			return
+		}
+		idemKey := c.GetHeader(header)
+		if idemKey == "" {
+			c.Next()
+			return
+		}
+
+		body, err := peekBody(c)
+		if err != nil {
+			response.ParamError(c, "common.read_body_failed")
+			c.Abort()
+			return
+		}
+
+		var userID string
+		if id, ok := AuthUserID(c); ok {
+			userID = strconv.FormatInt(id, 10)
+		}
+		sum := sha256.Sum256(body)
+		record := &model.IdempotencyRecord{
+			Key:         requestAppID(c) + ":" + userID + ":" + idemKey + ":" + method + ":" + c.FullPath(),
+			RequestHash: hex.EncodeToString(sum[:]),
+		}
+
+		ctx := c.Request.Context()
+		acquired, existing, err := store.Begin(ctx, record, processingTimeout)
+		if err != nil {
+			// 存储都不可用时放行，由服务内部的 request_id 校验兜底
+			idemLog.ErrorContext(ctx, "占用幂等键失败，跳过幂等处理", "key", record.Key, "err", err)
+			c.Next()
+			return
+		}
+
+		if !acquired {
+			handleDuplicate(c, store, record, existing, maxWait)
+			return
+		}
+
+		processingValue, _ := json.Marshal(record)
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		completed := false
+		defer func() {
+			// 业务 panic 或处理失败时释放幂等键，panic 继续交给 RecoveryMiddleware
+			if !completed {
+				store.Release(context.WithoutCancel(ctx), record, processingValue)
+			}
+		}()
+
+		c.Next()
+
+		if !shouldStoreResponse(c.Writer.Status(), recorder.body.Bytes()) {
+			return
+		}
+
+		record.StatusCode = c.Writer.Status()
+		record.ContentType = c.Writer.Header().Get("Content-Type")
+		record.ResponseBody = recorder.body.String()
+		if err := store.Complete(context.WithoutCancel(ctx), record, ttl); err != nil {
+			idemLog.ErrorContext(ctx, "保存响应失败", "key", record.Key, "err", err)
+			return
+		}
+		completed = true
+	}
+}
+
+// handleDuplicate 处理重复请求：摘要不同返回冲突，已完成回放响应，处理中等待
+func handleDuplicate(c *gin.Context, store *IdempotencyStore, record, existing *model.IdempotencyRecord, maxWait time.Duration) {
+	deadline := time.Now().Add(maxWait)
+	for {
+		if existing == nil {
+			// 首个请求失败释放了幂等键，让客户端重试
+			response.Conflict(c, response.CodeRequestProcessing, "idempotency.failed")
+			c.Abort()
+			return
+		}
+		if existing.RequestHash != record.RequestHash {
+			response.Conflict(c, response.CodeIdempotencyKeyReused, "idempotency.key_reused")
+			c.Abort()
+			return
+		}
+		if existing.Status == model.IdempotencyStatusCompleted {
+			c.Header(idempotencyReplayedHeader, "true")
+			c.Data(existing.StatusCode, existing.ContentType, []byte(existing.ResponseBody))
+			c.Abort()
+			return
+		}
+		if !time.Now().Before(deadline) {
+			response.Conflict(c, response.CodeRequestProcessing, "idempotency.processing")
+			c.Abort()
+			return
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			c.Abort()
+			return
+		case <-time.After(idempotencyPollInterval):
+		}
+
+		var err error
+		existing, err = store.Get(c.Request.Context(), record.Key)
+		if err != nil {
+			response.ServerError(c, "idempotency.lookup_failed")
+			c.Abort()
+			return
+		}
+	}
+}
+
+// shouldStoreResponse 服务端错误、可重试的错误（锁竞争、乐观锁冲突等暂时性错误）和鉴权、权限失败不保存，
+// 允许客户端用同一个幂等键重试
+func shouldStoreResponse(status int, body []byte) bool {
+	if status >= http.StatusInternalServerError || status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return false
+	}
+	var resp response.Response
+	if err := json.Unmarshal(body, &resp); err == nil && (resp.Code == response.CodeServerError || resp.Retryable) {
+		return false
+	}
+	return true
+}
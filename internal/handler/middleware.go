@@ -1,37 +1,128 @@
 package handler
 
 import (
-	"log"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
 	"time"
 
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/infrastructure/metrics"
+	"paysystem/internal/infrastructure/tracing"
+	"paysystem/pkg/response"
+
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// LoggerMiddleware 日志中间件
+// RequestIDHeader 请求ID，上游没有传时生成一个，并在响应头中返回
+const RequestIDHeader = "X-Request-ID"
+
+var httpLog = logging.For("HTTP")
+
+// LoggerMiddleware 日志中间件：生成 request_id 放入日志上下文，请求结束后记录访问日志
 func LoggerMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
-		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
+
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logging.WithFields(c.Request.Context(), "request_id", requestID))
 
 		// 处理请求
 		c.Next()
 
-		// 记录日志
-		latency := time.Since(start)
+		// 下游中间件（如用户鉴权）可能往上下文里追加了字段，用处理后的 ctx 记录
 		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		httpLog.Log(c.Request.Context(), level, "请求完成",
+			"status", status,
+			"latency_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"query", logging.RedactQuery(c.Request.URL.RawQuery),
+			"route", c.FullPath(),
+		)
+	}
+}
 
-		if query != "" {
-			path = path + "?" + query
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// MetricsMiddleware HTTP 请求数、耗时、处理中请求数
+// 路由标签使用注册的路由模板（FullPath），未匹配的路由统一记为 unmatched
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		metrics.HTTPInFlight.Inc()
+		defer metrics.HTTPInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// TraceIDHeader 响应头中的 trace ID，排查问题时按它在链路系统中搜索
+const TraceIDHeader = "X-Trace-ID"
+
+// TracingMiddleware 为每个请求创建服务端 span，上游带了 traceparent 时继续上游的 trace
+func TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
 		}
 
-		log.Printf("[HTTP] %d | %13v | %15s | %-7s %s",
-			status,
-			latency,
-			c.ClientIP(),
-			c.Request.Method,
-			path,
+		ctx := tracing.ExtractHTTP(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Tracer().Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+			),
 		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.HasTraceID() {
+			c.Header(TraceIDHeader, sc.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
 	}
 }
 
@@ -40,10 +131,10 @@ func RecoveryMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("[PANIC] %v", err)
+				httpLog.ErrorContext(c.Request.Context(), "请求处理 panic", "panic", err, "stack", string(debug.Stack()))
 				c.AbortWithStatusJSON(500, gin.H{
 					"code":    500,
-					"message": "服务器内部错误",
+					"message": response.T(c, "common.internal"),
 				})
 			}
 		}()
@@ -56,7 +147,8 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-Request-ID")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-Request-ID, X-App-ID, X-Timestamp, X-Nonce, X-Signature, traceparent, tracestate")
+		c.Header("Access-Control-Expose-Headers", "Idempotent-Replayed, Retry-After, X-Request-ID, X-Trace-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -66,3 +158,13 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// peekBody 读取请求体并放回，后续中间件和处理器仍可再次读取
+func peekBody(c *gin.Context) ([]byte, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -2,63 +2,125 @@ package handler
 
 import (
 	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/cache"
+	"paysystem/internal/infrastructure/lock"
+	"paysystem/internal/infrastructure/metrics"
+	"paysystem/internal/model"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 )
 
-// SetupRouter 配置路由
-func SetupRouter(db *gorm.DB, rdb *redis.Client, cfg *config.Config) *gin.Engine {
+// SetupRouter 配置路由，鉴权配置有误时返回错误
+func SetupRouter(db *gorm.DB, rdb *redis.Client, locker lock.Locker, cfg *config.Config) (*gin.Engine, error) {
 	// 设置 gin 为发布模式（减少日志输出）
 	gin.SetMode(gin.ReleaseMode)
 
 	r := gin.New()
 
 	// 注册中间件
-	r.Use(RecoveryMiddleware())
+	// 链路追踪在最外层，之后的日志都能带上 trace_id
+	r.Use(TracingMiddleware())
 	r.Use(LoggerMiddleware())
+	r.Use(RecoveryMiddleware())
 	r.Use(CORSMiddleware())
+	if cfg.Metrics.Enabled {
+		metricsPath := cfg.Metrics.Path
+		if metricsPath == "" {
+			metricsPath = "/metrics"
+		}
+		r.Use(MetricsMiddleware())
+		r.GET(metricsPath, gin.WrapH(metrics.Handler()))
+	}
 
 	// 创建处理器
-	h := NewHandler(db, rdb, cfg)
+	h := NewHandler(db, rdb, locker, cfg)
 
 	// API 路由组
+	// 中间件顺序：服务鉴权 -> 用户鉴权 -> 限流（按已认证的调用方计数）-> 权限 -> 幂等（被拒绝的请求不会占用幂等键）
 	api := r.Group("/api/v1")
+	if cfg.Auth.Enabled {
+		api.Use(NewAppAuthenticator(db, rdb, &cfg.Auth).Middleware())
+	}
+	if cfg.JWT.Enabled {
+		userAuth, err := UserAuthMiddleware(&cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		api.Use(userAuth)
+	}
+	if cfg.RateLimit.Enabled {
+		api.Use(RateLimitMiddleware(cache.NewRateLimiter(rdb), &cfg.RateLimit, cfg.JWT.Enabled))
+	}
+	// 权限按路由检查，幂等跟在权限之后挂到每个路由上，否则权限不足的 403 会被保存并重放
+	var idempotent []gin.HandlerFunc
+	if cfg.Idempotency.Enabled {
+		idempotent = append(idempotent, IdempotencyMiddleware(NewIdempotencyStore(rdb, db), &cfg.Idempotency))
+	}
+	scoped := func(scope string, handler gin.HandlerFunc) []gin.HandlerFunc {
+		handlers := append([]gin.HandlerFunc{RequireScope(scope)}, idempotent...)
+		return append(handlers, handler)
+	}
 	{
 		// 账户相关
 		account := api.Group("/account")
 		{
-			account.GET("/balance", h.GetBalance)
-			account.POST("/recharge", h.Recharge)
+			account.GET("/balance", scoped(model.ScopeRead, h.GetBalance)...)
+			account.POST("/recharge", scoped(model.ScopeRecharge, h.Recharge)...)
 		}
 
 		// 订单相关
 		order := api.Group("/order")
 		{
-			order.POST("/create", h.CreateOrder)
-			order.GET("/detail", h.GetOrder)
-			order.GET("/list", h.ListOrders)
-			order.POST("/cancel", h.CancelOrder)
+			order.POST("/create", scoped(model.ScopePay, h.CreateOrder)...)
+			order.GET("/detail", scoped(model.ScopeRead, h.GetOrder)...)
+			order.GET("/list", scoped(model.ScopeRead, h.ListOrders)...)
+			order.POST("/cancel", scoped(model.ScopePay, h.CancelOrder)...)
 		}
 
 		// 支付相关
 		pay := api.Group("/pay")
 		{
-			pay.POST("/execute", h.PayOrder)
+			pay.POST("/execute", scoped(model.ScopePay, h.PayOrder)...)
 		}
 
 		// 退款相关
 		refund := api.Group("/refund")
 		{
-			refund.POST("/execute", h.RefundOrder)
+			refund.POST("/execute", scoped(model.ScopeRefund, h.RefundOrder)...)
+		}
+	}
+
+	// 管理后台
+	if cfg.Admin.Enabled {
+		ah := NewAdminHandler(db, rdb, locker, cfg)
+		adminAuth, err := ah.AuthMiddleware(&cfg.Admin.JWT)
+		if err != nil {
+			return nil, err
+		}
+		admin := r.Group("/admin/v1", adminAuth)
+		{
+			admin.GET("/orders", RequirePermission(model.AdminPermOrderRead), ah.SearchOrders)
+			admin.POST("/orders/refund", RequirePermission(model.AdminPermOrderRefund), ah.RefundOrder)
+			admin.POST("/accounts/adjust", RequirePermission(model.AdminPermAccountAdjust), ah.AdjustBalance)
+			admin.POST("/accounts/freeze", RequirePermission(model.AdminPermAccountFreeze), ah.FreezeAccount)
+			admin.POST("/outbox/replay", RequirePermission(model.AdminPermOutboxReplay), ah.ReplayOutbox)
+
+			admin.GET("/approvals", RequirePermission(model.AdminPermApprovalRead), ah.ListApprovals)
+			admin.POST("/approvals/approve", RequirePermission(model.AdminPermApprovalReview), ah.ApproveApproval)
+			admin.POST("/approvals/reject", RequirePermission(model.AdminPermApprovalReview), ah.RejectApproval)
+
+			admin.GET("/audit-logs", RequirePermission(model.AdminPermAuditRead), ah.ListAuditLogs)
+
+			admin.GET("/leaders", RequirePermission(model.AdminPermSystemRead), ah.ListLeaders)
 		}
 	}
 
-	// 健康检查
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// 健康检查：/health 保留给旧的探针，等同于存活检查
+	r.GET("/health", LiveCheck)
+	r.GET("/health/live", LiveCheck)
+	r.GET("/health/ready", ReadyCheck)
 
-	return r
+	return r, nil
 }
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ============================================================================
+// Redis 延迟队列
+// ============================================================================
+//
+// 基于 Sorted Set 实现：member 为业务ID（如订单号），score 为到期时间（毫秒时间戳）
+//
+//   入队：ZADD key <到期时间> <member>
+//   出队：取出 score <= 当前时间 的 member，并从集合中删除
+//
+// 出队使用 Lua 脚本保证"查询+删除"的原子性，多个实例同时拉取时，
+// 同一个 member 只会被一个实例拿到。
+//
+// 【注意】出队后如果进程崩溃，该 member 就丢失了，调用方需要有兜底扫描。
+//
+// ============================================================================
+
+var popDueScript = redis.NewScript(`
+	local members = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+	if #members > 0 then
+		redis.call("ZREM", KEYS[1], unpack(members))
+	end
+	return members
+`)
+
+// DelayQueue 延迟队列
+type DelayQueue struct {
+	client *redis.Client
+	key    string
+}
+
+// NewDelayQueue 创建延迟队列
+func NewDelayQueue(client *redis.Client, key string) *DelayQueue {
+	return &DelayQueue{
+		client: client,
+		key:    key,
+	}
+}
+
+// Add 添加元素，到 dueAt 时可被取出；重复添加会覆盖到期时间
+func (q *DelayQueue) Add(ctx context.Context, member string, dueAt time.Time) error {
+	return q.client.ZAdd(ctx, q.key, &redis.Z{
+		Score:  float64(dueAt.UnixMilli()),
+		Member: member,
+	}).Err()
+}
+
+// Remove 删除元素
+func (q *DelayQueue) Remove(ctx context.Context, member string) error {
+	return q.client.ZRem(ctx, q.key, member).Err()
+}
+
+// PopDue 取出最多 limit 个已到期的元素
+func (q *DelayQueue) PopDue(ctx context.Context, limit int) ([]string, error) {
+	now := time.Now().UnixMilli()
+	return popDueScript.Run(ctx, q.client, []string{q.key}, now, limit).StringSlice()
+}
+
+// Len 队列长度
+func (q *DelayQueue) Len(ctx context.Context) (int64, error) {
+	return q.client.ZCard(ctx, q.key).Result()
+}
+
+// NewOrderExpireQueue 订单超时队列，member 为订单号，score 为订单过期时间
+func NewOrderExpireQueue(client *redis.Client) *DelayQueue {
+	return NewDelayQueue(client, "pay:order:expire")
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ============================================================================
+// Redis 令牌桶限流
+// ============================================================================
+//
+// 每个限流对象一个 Hash：tokens 当前令牌数，ts 上次更新时间（毫秒）
+//
+//   取令牌：按距上次更新的时间补充令牌（rate 个/秒，最多 burst 个），够 1 个则放行并扣减，
+//   不够则拒绝，并算出还要等多久才有下一个令牌（Retry-After）
+//
+// 整个过程在 Lua 中原子执行，时间取 Redis 服务器时间，多实例之间不受本机时钟影响。
+// 长时间没有请求时 key 自动过期（相当于桶已装满）。
+//
+// ============================================================================
+
+var tokenBucketScript = redis.NewScript(`
+	redis.replicate_commands()
+	local now = redis.call("TIME")
+	local nowMs = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+	local rate = tonumber(ARGV[1])
+	local burst = tonumber(ARGV[2])
+
+	local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+	local tokens = tonumber(bucket[1])
+	local ts = tonumber(bucket[2])
+	if tokens == nil or ts == nil then
+		tokens = burst
+		ts = nowMs
+	end
+	tokens = math.min(burst, tokens + math.max(0, nowMs - ts) * rate / 1000)
+
+	local allowed = 0
+	local retryAfter = 0
+	if tokens >= 1 then
+		tokens = tokens - 1
+		allowed = 1
+	else
+		retryAfter = math.ceil((1 - tokens) * 1000 / rate)
+	end
+
+	redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", nowMs)
+	redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+	return {allowed, retryAfter}
+`)
+
+// RateLimiter 分布式令牌桶限流器
+type RateLimiter struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRateLimiter 创建限流器
+func NewRateLimiter(client *redis.Client) *RateLimiter {
+	return &RateLimiter{
+		client: client,
+		prefix: "pay:ratelimit:",
+	}
+}
+
+// Allow 从 key 对应的桶中取一个令牌，rate 为每秒补充的令牌数，burst 为桶容量
+// 被拒绝时返回需要等待的时间
+func (l *RateLimiter) Allow(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	result, err := tokenBucketScript.Run(ctx, l.client, []string{l.prefix + key}, rate, burst).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
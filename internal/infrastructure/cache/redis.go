@@ -7,10 +7,13 @@ import (
 	"time"
 
 	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/logging"
 
 	"github.com/go-redis/redis/v8"
 )
 
+var redisLog = logging.For("Redis")
+
 var RedisClient *redis.Client
 
 func InitRedis(cfg *config.RedisConfig) *redis.Client {
@@ -28,6 +31,28 @@ func InitRedis(cfg *config.RedisConfig) *redis.Client {
 	}
 
 	RedisClient = client
-	log.Println("Redis 连接成功")
+	redisLog.Info("Redis 连接成功")
 	return client
 }
+
+// NewRedlockClients 创建 Redlock 各节点的客户端
+// 部分节点不可用时只打印日志，Redlock 只要多数节点可用即可加锁
+func NewRedlockClients(cfg *config.RedisConfig) []*redis.Client {
+	clients := make([]*redis.Client, 0, len(cfg.RedlockNodes))
+	for _, node := range cfg.RedlockNodes {
+		client := redis.NewClient(&redis.Options{
+			Addr:     fmt.Sprintf("%s:%d", node.Host, node.Port),
+			Password: node.Password,
+			DB:       node.DB,
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := client.Ping(ctx).Err(); err != nil {
+			redisLog.ErrorContext(ctx, "Redlock 节点连接失败", "host", node.Host, "port", node.Port, "err", err)
+		}
+		cancel()
+
+		clients = append(clients, client)
+	}
+	return clients
+}
@@ -6,13 +6,15 @@ import (
 	"time"
 
 	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/logging"
 	"paysystem/internal/model"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
+var mysqlLog = logging.For("MySQL")
+
 var DB *gorm.DB
 
 // InitMySQL 初始化 MySQL 连接
@@ -26,7 +28,7 @@ func InitMySQL(cfg *config.MySQLConfig) *gorm.DB {
 	)
 
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: logging.NewGormLogger(time.Duration(cfg.SlowQueryMs) * time.Millisecond),
 	})
 	if err != nil {
 		log.Fatalf("连接 MySQL 失败: %v", err)
@@ -48,12 +50,19 @@ func InitMySQL(cfg *config.MySQLConfig) *gorm.DB {
 		&model.PayOrder{},
 		&model.AccountTransaction{},
 		&model.OutboxMessage{},
+		&model.OutboxMessageArchive{},
+		&model.InboxMessage{},
+		&model.IdempotencyRecord{},
+		&model.AppCredential{},
+		&model.AdminUser{},
+		&model.AdminApproval{},
+		&model.AdminAuditLog{},
 	)
 	if err != nil {
 		log.Fatalf("自动迁移表结构失败: %v", err)
 	}
 
 	DB = db
-	log.Println("MySQL 连接成功")
+	mysqlLog.Info("MySQL 连接成功")
 	return db
 }
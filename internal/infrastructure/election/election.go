@@ -0,0 +1,264 @@
+package election
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/logging"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ============================================================================
+// 主节点选举（Redis 租约）
+// ============================================================================
+//
+// 【为什么需要选举？】
+//
+// 超时关单、支付中补偿这类任务每个实例都跑的话，多个实例会同时处理同一批订单，
+// 只有一个能改成功，其余的都报订单状态不正确，白白占用数据库和锁。
+// 这类任务同一时刻只需要一个实例执行。
+//
+// 【租约】
+//
+// 每类任务一个 key：pay:leader:<name>，value 为实例 ID，带过期时间 ttl。
+//
+//   - 每隔 ttl/3 执行一次"没有主节点则抢占，是自己则续期"（Lua 脚本，原子）
+//   - 主节点正常退出时主动删除 key，其他实例在下一次轮询（ttl/3 内）接管
+//   - 主节点崩溃时 key 最多 ttl 后过期，其他实例随之接管
+//   - 续期持续失败（Redis 不可用）超过 2/3 ttl 时主动退位，保证租约过期前已经停止执行，
+//     不会与新主节点同时执行
+//
+// 【注意】退位只在两批任务之间生效，已经开始的批次会执行完。
+// 任务本身依然要保证幂等（订单状态条件更新），选举只是避免重复劳动。
+//
+// ============================================================================
+
+var electionLog = logging.For("Election")
+
+const (
+	keyPrefix  = "pay:leader:"
+	defaultTTL = 10 * time.Second
+)
+
+// acquireScript 没有主节点时抢占，已经是自己时续期，返回 1 表示持有租约
+var acquireScript = redis.NewScript(`
+	local holder = redis.call("GET", KEYS[1])
+	if holder == false then
+		redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+		return 1
+	end
+	if holder == ARGV[1] then
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		return 1
+	end
+	return 0
+`)
+
+// releaseScript 只删除自己持有的租约
+var releaseScript = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	end
+	return 0
+`)
+
+// Status 一个选举的当前状态
+type Status struct {
+	Name             string `json:"name"`
+	Leader           string `json:"leader"` // 当前主节点的实例 ID，没有主节点时为空
+	LeaseRemainingMs int64  `json:"lease_remaining_ms"`
+	Instance         string `json:"instance"`  // 本实例 ID
+	IsLeader         bool   `json:"is_leader"` // 本实例是否为主节点
+}
+
+// Elector 一类任务的主节点选举
+type Elector struct {
+	rdb      *redis.Client
+	name     string
+	key      string
+	id       string
+	ttl      time.Duration
+	interval time.Duration
+
+	leader atomic.Bool
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Elector{}
+)
+
+// New 创建选举，name 为任务名，同名的选举在所有实例间互斥
+func New(rdb *redis.Client, name string, cfg *config.LeaderConfig) *Elector {
+	ttl := time.Duration(cfg.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	e := &Elector{
+		rdb:      rdb,
+		name:     name,
+		key:      keyPrefix + name,
+		id:       InstanceID(cfg),
+		ttl:      ttl,
+		interval: ttl / 3,
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	registryMu.Lock()
+	registry[name] = e
+	registryMu.Unlock()
+	return e
+}
+
+// InstanceID 本实例 ID：配置了则使用配置，否则为 主机名-进程号
+func InstanceID(cfg *config.LeaderConfig) string {
+	if cfg.InstanceID != "" {
+		return cfg.InstanceID
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// IsLeader 本实例当前是否为主节点，任务每批开始前检查
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Start 参与选举，阻塞到 Stop
+func (e *Elector) Start(ctx context.Context) {
+	defer close(e.done)
+	electionLog.InfoContext(ctx, "参与主节点选举", "name", e.name, "instance", e.id, "ttl", e.ttl)
+
+	var lastRenewed time.Time
+	e.campaign(ctx, &lastRenewed)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.resign(context.WithoutCancel(ctx))
+			return
+		case <-e.stopCh:
+			e.resign(ctx)
+			return
+		case <-ticker.C:
+			e.campaign(ctx, &lastRenewed)
+		}
+	}
+}
+
+// Stop 退出选举：是主节点时释放租约，其他实例可以立即接管
+// 需要在依赖它的任务停止之后调用
+func (e *Elector) Stop(ctx context.Context) error {
+	close(e.stopCh)
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待退出选举超时: %w", ctx.Err())
+	}
+}
+
+// campaign 抢占或续期一次
+func (e *Elector) campaign(ctx context.Context, lastRenewed *time.Time) {
+	callCtx, cancel := context.WithTimeout(ctx, e.interval)
+	held, err := acquireScript.Run(callCtx, e.rdb, []string{e.key}, e.id, e.ttl.Milliseconds()).Int()
+	cancel()
+
+	switch {
+	case err != nil:
+		// 续期失败先保持现状，快到租约过期时退位
+		if e.IsLeader() && time.Since(*lastRenewed) >= e.ttl-e.interval {
+			e.setLeader(ctx, false)
+			electionLog.ErrorContext(ctx, "续期租约持续失败，主动退位", "name", e.name, "err", err)
+			return
+		}
+		electionLog.WarnContext(ctx, "选举请求失败", "name", e.name, "err", err)
+	case held == 1:
+		*lastRenewed = time.Now()
+		e.setLeader(ctx, true)
+	default:
+		e.setLeader(ctx, false)
+	}
+}
+
+func (e *Elector) setLeader(ctx context.Context, leader bool) {
+	if e.leader.Swap(leader) == leader {
+		return
+	}
+	if leader {
+		electionLog.InfoContext(ctx, "成为主节点", "name", e.name, "instance", e.id)
+	} else {
+		electionLog.WarnContext(ctx, "不再是主节点", "name", e.name, "instance", e.id)
+	}
+}
+
+// resign 释放自己持有的租约
+func (e *Elector) resign(ctx context.Context) {
+	if !e.leader.Swap(false) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+	if err := releaseScript.Run(ctx, e.rdb, []string{e.key}, e.id).Err(); err != nil {
+		electionLog.ErrorContext(ctx, "释放租约失败，等待租约过期", "name", e.name, "err", err)
+		return
+	}
+	electionLog.InfoContext(ctx, "已释放主节点租约", "name", e.name, "instance", e.id)
+}
+
+// Status 查询当前主节点
+func (e *Elector) Status(ctx context.Context) (*Status, error) {
+	pipe := e.rdb.Pipeline()
+	getCmd := pipe.Get(ctx, e.key)
+	ttlCmd := pipe.PTTL(ctx, e.key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	status := &Status{Name: e.name, Instance: e.id, IsLeader: e.IsLeader()}
+	if leader, err := getCmd.Result(); err == nil {
+		status.Leader = leader
+		if remaining := ttlCmd.Val(); remaining > 0 {
+			status.LeaseRemainingMs = remaining.Milliseconds()
+		}
+	}
+	return status, nil
+}
+
+// Statuses 本实例参与的所有选举的状态，按名称排序
+func Statuses(ctx context.Context) ([]*Status, error) {
+	registryMu.Lock()
+	electors := make([]*Elector, 0, len(registry))
+	for _, e := range registry {
+		electors = append(electors, e)
+	}
+	registryMu.Unlock()
+	sort.Slice(electors, func(i, j int) bool { return electors[i].name < electors[j].name })
+
+	statuses := make([]*Status, 0, len(electors))
+	for _, e := range electors {
+		status, err := e.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
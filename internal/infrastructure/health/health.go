@@ -0,0 +1,283 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================================================
+// 健康检查
+// ============================================================================
+//
+// 【存活 live】进程能处理请求即可，不检查依赖：依赖故障时重启 Pod 解决不了问题，
+// 反而会让所有实例一起重启。
+//
+// 【就绪 ready】逐项检查依赖（MySQL、Redis、Kafka、Outbox 积压、后台任务心跳）。
+// 只有关键项（Configure 配置，未配置时每一项都是关键项）失败才返回未就绪，编排系统把该实例摘出流量；
+// 其他项失败只标记为降级（degraded），仍然就绪。某个实例连不上依赖时应当摘掉它的流量，
+// 所以默认依赖都是关键项；确认依赖故障时实例仍能处理请求（锁有降级实现、消息有 Outbox 兜底），
+// 并且不希望共享依赖故障时整个集群同时摘流量，才把它从关键项中去掉。
+//
+// 【后台任务心跳】任务每轮循环调用 Beat，超过 间隔 × staleFactor（至少 minStaleAfter）
+// 没有心跳视为卡死。单批处理慢一点不算，避免短间隔的任务被误判。
+//
+// 【优雅关闭】收到退出信号后先 SetShuttingDown，就绪检查立即返回未就绪，
+// 等负载均衡摘掉流量后再关闭 HTTP 服务。
+//
+// ============================================================================
+
+const (
+	StatusUp       = "up"
+	StatusDegraded = "degraded" // 非关键项失败，仍然就绪
+	StatusDown     = "down"
+
+	defaultTimeout     = 2 * time.Second
+	defaultStaleFactor = 3
+	minStaleAfter      = 30 * time.Second
+)
+
+// CheckFunc 单项检查，返回附加信息（可为 nil），出错表示该项不可用
+type CheckFunc func(ctx context.Context) (any, error)
+
+// ComponentStatus 单项检查结果
+type ComponentStatus struct {
+	Status    string `json:"status"`
+	Critical  bool   `json:"critical"` // 失败时是否导致未就绪
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	Detail    any    `json:"detail,omitempty"`
+}
+
+// Report 就绪检查结果
+type Report struct {
+	Status     string                      `json:"status"`
+	Reason     string                      `json:"reason,omitempty"`
+	Components map[string]*ComponentStatus `json:"components,omitempty"`
+}
+
+// Ready 是否就绪，降级也算就绪
+func (r *Report) Ready() bool {
+	return r.Status != StatusDown
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// JobHeartbeat 后台任务心跳
+type JobHeartbeat struct {
+	LastBeat time.Time `json:"last_beat"`
+	Interval string    `json:"interval"`
+	Stale    bool      `json:"stale"`
+}
+
+type job struct {
+	interval time.Duration
+	lastBeat atomic.Int64 // UnixNano
+}
+
+var (
+	mu          sync.RWMutex
+	checks      []check
+	jobs        = map[string]*job{}
+	timeout     = defaultTimeout
+	staleFactor = defaultStaleFactor
+	critical    map[string]bool // 关键项，nil 表示每一项都是关键项
+
+	shuttingDown atomic.Bool
+)
+
+// Configure 设置单项检查超时、心跳过期倍数和关键项，<= 0 或为空时使用默认值
+// 关键项为检查名（后台任务心跳为 jobs），只有关键项失败才返回未就绪
+func Configure(checkTimeout time.Duration, jobStaleFactor int, criticalChecks []string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if checkTimeout > 0 {
+		timeout = checkTimeout
+	}
+	if jobStaleFactor > 0 {
+		staleFactor = jobStaleFactor
+	}
+	if len(criticalChecks) > 0 {
+		critical = make(map[string]bool, len(criticalChecks))
+		for _, name := range criticalChecks {
+			critical[name] = true
+		}
+	}
+}
+
+// isCritical 调用方需持有 mu
+func isCritical(name string) bool {
+	return critical == nil || critical[name]
+}
+
+// Register 注册一项就绪检查，同名覆盖
+func Register(name string, fn CheckFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range checks {
+		if checks[i].name == name {
+			checks[i].fn = fn
+			return
+		}
+	}
+	checks = append(checks, check{name: name, fn: fn})
+}
+
+// RegisterJob 登记后台任务及其循环间隔，登记时记一次心跳
+func RegisterJob(name string, interval time.Duration) {
+	j := &job{interval: interval}
+	j.lastBeat.Store(time.Now().UnixNano())
+
+	mu.Lock()
+	jobs[name] = j
+	mu.Unlock()
+}
+
+// Beat 记录后台任务心跳，未登记的任务忽略
+func Beat(name string) {
+	mu.RLock()
+	j := jobs[name]
+	mu.RUnlock()
+	if j != nil {
+		j.lastBeat.Store(time.Now().UnixNano())
+	}
+}
+
+// SetShuttingDown 进入关闭流程，之后就绪检查一律返回未就绪
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// ShuttingDown 是否正在关闭
+func ShuttingDown() bool {
+	return shuttingDown.Load()
+}
+
+// Check 并发执行所有就绪检查
+func Check(ctx context.Context) *Report {
+	if ShuttingDown() {
+		return &Report{Status: StatusDown, Reason: "shutting_down"}
+	}
+
+	mu.RLock()
+	list := make([]check, len(checks))
+	copy(list, checks)
+	checkTimeout := timeout
+	criticalOf := make(map[string]bool, len(list)+1)
+	for _, c := range list {
+		criticalOf[c.name] = isCritical(c.name)
+	}
+	criticalOf["jobs"] = isCritical("jobs")
+	mu.RUnlock()
+
+	report := &Report{Status: StatusUp, Components: make(map[string]*ComponentStatus, len(list)+1)}
+
+	var (
+		wg       sync.WaitGroup
+		resultMu sync.Mutex
+	)
+	for _, c := range list {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+			status := run(ctx, c.fn, checkTimeout)
+			resultMu.Lock()
+			report.Components[c.name] = status
+			resultMu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	if jobStatus := checkJobs(); jobStatus != nil {
+		report.Components["jobs"] = jobStatus
+	}
+	for name, status := range report.Components {
+		status.Critical = criticalOf[name]
+		if status.Status == StatusUp {
+			continue
+		}
+		if status.Critical {
+			report.Status = StatusDown
+		} else if report.Status == StatusUp {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// run 执行单项检查，超时按失败处理（检查本身可能不支持 ctx，放在 goroutine 中执行）
+func run(ctx context.Context, fn CheckFunc, checkTimeout time.Duration) *ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	type result struct {
+		detail any
+		err    error
+	}
+	start := time.Now()
+	done := make(chan result, 1)
+	go func() {
+		detail, err := fn(ctx)
+		done <- result{detail: detail, err: err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res.err = fmt.Errorf("检查超时: %w", ctx.Err())
+	}
+
+	status := &ComponentStatus{
+		Status:    StatusUp,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Detail:    res.detail,
+	}
+	if res.err != nil {
+		status.Status = StatusDown
+		status.Error = res.err.Error()
+	}
+	return status
+}
+
+// checkJobs 检查后台任务心跳，没有登记任务时返回 nil
+func checkJobs() *ComponentStatus {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(jobs))
+	for name := range jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	status := &ComponentStatus{Status: StatusUp}
+	detail := make(map[string]JobHeartbeat, len(jobs))
+	for _, name := range names {
+		j := jobs[name]
+		lastBeat := time.Unix(0, j.lastBeat.Load())
+		staleAfter := j.interval * time.Duration(staleFactor)
+		if staleAfter < minStaleAfter {
+			staleAfter = minStaleAfter
+		}
+		stale := time.Since(lastBeat) > staleAfter
+		detail[name] = JobHeartbeat{LastBeat: lastBeat, Interval: j.interval.String(), Stale: stale}
+		if stale {
+			status.Status = StatusDown
+			if status.Error == "" {
+				status.Error = "后台任务心跳超时: " + name
+			}
+		}
+	}
+	status.Detail = detail
+	return status
+}
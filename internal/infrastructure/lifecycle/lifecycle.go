@@ -0,0 +1,148 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"paysystem/internal/infrastructure/logging"
+)
+
+// ============================================================================
+// 组件生命周期管理
+// ============================================================================
+//
+// 组件按依赖顺序 Append（被依赖的在前），启动时顺序执行 OnStart，关闭时逆序执行 OnStop：
+//
+//   启动：MySQL -> Redis -> Kafka 生产者 -> 后台任务 -> 消费者 -> HTTP
+//   关闭：HTTP（摘流量、等在途请求）-> 消费者 -> 后台任务（等当前批次）-> Kafka -> Redis -> MySQL
+//
+// 这样关闭 Kafka 时不会还有发送中的消息，关闭 MySQL 时不会还有执行中的事务。
+//
+// 【上下文】OnStart 收到的 ctx 在所有组件关闭之后才取消，后台任务可以用它跑循环，
+// 退出由 OnStop 通知；收到退出信号时不会直接取消，避免打断执行到一半的批次。
+//
+// 【失败】启动失败时逆序关闭已启动的组件；运行中组件异常退出调用 Fail 触发关闭。
+// Run 返回启动、运行、关闭过程中的所有错误，main 据此决定退出码。
+//
+// ============================================================================
+
+var lifecycleLog = logging.For("Lifecycle")
+
+// Hook 一个组件的启动、关闭函数，都可以为空
+// OnStart 不能阻塞，常驻的循环自己起 goroutine
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Manager 生命周期管理器
+type Manager struct {
+	hooks       []Hook
+	started     int
+	stopTimeout time.Duration
+
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+
+	failOnce sync.Once
+	failCh   chan error
+}
+
+// New 创建管理器，stopTimeout 为关闭所有组件的总时长
+func New(stopTimeout time.Duration) *Manager {
+	runCtx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		stopTimeout: stopTimeout,
+		runCtx:      runCtx,
+		cancelRun:   cancel,
+		failCh:      make(chan error, 1),
+	}
+}
+
+// Append 按依赖顺序追加组件
+func (m *Manager) Append(hook Hook) {
+	m.hooks = append(m.hooks, hook)
+}
+
+// Fail 运行中的组件异常退出，触发整体关闭；只记录第一个错误
+func (m *Manager) Fail(err error) {
+	m.failOnce.Do(func() {
+		m.failCh <- err
+	})
+}
+
+// Run 启动所有组件，阻塞到 ctx 取消（收到退出信号）或有组件失败，然后关闭所有组件
+func (m *Manager) Run(ctx context.Context) error {
+	if err := m.start(); err != nil {
+		return errors.Join(err, m.stop())
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		lifecycleLog.Info("收到退出信号，开始关闭")
+	case runErr = <-m.failCh:
+		lifecycleLog.Error("组件异常退出，开始关闭", "err", runErr)
+	}
+	return errors.Join(runErr, m.stop())
+}
+
+func (m *Manager) start() error {
+	for _, hook := range m.hooks {
+		if hook.OnStart != nil {
+			if err := hook.OnStart(m.runCtx); err != nil {
+				lifecycleLog.Error("组件启动失败", "name", hook.Name, "err", err)
+				return fmt.Errorf("启动 %s 失败: %w", hook.Name, err)
+			}
+		}
+		m.started++
+		lifecycleLog.Info("组件已启动", "name", hook.Name)
+	}
+	return nil
+}
+
+// stop 逆序关闭已启动的组件，某个组件关闭失败不影响后面的组件
+func (m *Manager) stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.stopTimeout)
+	defer cancel()
+	defer m.cancelRun()
+
+	var errs []error
+	for i := m.started - 1; i >= 0; i-- {
+		hook := m.hooks[i]
+		if hook.OnStop == nil {
+			continue
+		}
+		start := time.Now()
+		if err := hook.OnStop(ctx); err != nil {
+			lifecycleLog.Error("组件关闭失败", "name", hook.Name, "err", err)
+			errs = append(errs, fmt.Errorf("关闭 %s 失败: %w", hook.Name, err))
+			continue
+		}
+		lifecycleLog.Info("组件已关闭", "name", hook.Name, "cost_ms", time.Since(start).Milliseconds())
+	}
+	m.started = 0
+	return errors.Join(errs...)
+}
+
+// Runner 后台循环组件：Start 阻塞运行到停止，Stop 通知退出并等待 Start 返回
+type Runner interface {
+	Start(ctx context.Context)
+	Stop(ctx context.Context) error
+}
+
+// Background 把后台循环组件包装为 Hook
+func Background(name string, r Runner) Hook {
+	return Hook{
+		Name: name,
+		OnStart: func(ctx context.Context) error {
+			go r.Start(ctx)
+			return nil
+		},
+		OnStop: r.Stop,
+	}
+}
@@ -2,11 +2,15 @@ package lock
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"net/http"
 	"time"
 
+	"paysystem/pkg/errs"
+	"paysystem/pkg/response"
+
 	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
 )
 
 // ============================================================================
@@ -39,8 +43,9 @@ import (
 // ============================================================================
 
 var (
-	ErrLockFailed  = errors.New("获取分布式锁失败")
-	ErrLockExpired = errors.New("锁已过期")
+	ErrLockFailed  = errs.New(response.CodeSystemBusy, "lock.failed", http.StatusServiceUnavailable, true, "获取分布式锁失败")
+	ErrLockExpired = errs.New(response.CodeSystemBusy, "lock.expired", http.StatusServiceUnavailable, true, "锁已过期")
+	ErrLockLost    = errs.New(response.CodeSystemBusy, "lock.lost", http.StatusServiceUnavailable, true, "锁已丢失")
 )
 
 // DistributedLock 分布式锁
@@ -49,6 +54,10 @@ type DistributedLock struct {
 	key        string        // 锁的 key
 	value      string        // 锁的 value（用于验证锁的持有者）
 	expiration time.Duration // 锁的过期时间
+	token      int64         // 本次加锁获得的 fencing token
+	fair       bool          // 是否使用公平等待（见 fair_lock.go）
+
+	watchdog watchdog
 }
 
 // NewDistributedLock 创建分布式锁
@@ -61,40 +70,80 @@ func NewDistributedLock(client *redis.Client, key, value string, expiration time
 	}
 }
 
+// ============================================================================
+// Fencing Token
+// ============================================================================
+//
+// 【TTL 解决不了的问题】
+//
+//   A 获取锁 -> A 发生长时间 GC/停顿 -> 锁过期 -> B 获取锁并写入数据
+//   -> A 恢复，仍然以为自己持有锁，写入旧数据，覆盖了 B 的结果
+//
+// 【Fencing Token】
+//
+// 每次加锁成功时通过 INCR 得到一个单调递增的 token，写数据时带上 token，
+// 存储层记录见过的最大 token，拒绝 token 更小的写入：
+//
+//   A 加锁 token=33 -> 停顿 -> 锁过期 -> B 加锁 token=34，写入（记录 34）
+//   -> A 恢复，带 token=33 写入，33 < 34，被拒绝
+//
+// 所有锁共用一个计数器，token 在全局范围内单调递增。
+//
+// ============================================================================
+
+// fencingCounterKey 全局 fencing token 计数器
+const fencingCounterKey = "lock:fencing:counter"
+
+// acquireScript 加锁成功后在同一个脚本里生成 fencing token，加锁失败返回 0
+var acquireScript = redis.NewScript(`
+	if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+		return redis.call("INCR", KEYS[2])
+	end
+	return 0
+`)
+
 // TryLock 尝试获取锁（非阻塞）
 //
-// 【关键点】使用 SetNX 命令，只有当 key 不存在时才能设置成功
+// 【关键点】使用 SET NX，只有当 key 不存在时才能设置成功
 // 这保证了同一时刻只有一个客户端能获取到锁
 func (l *DistributedLock) TryLock(ctx context.Context) (bool, error) {
-	// SET key value NX EX timeout
+	// SET key value NX PX timeout + INCR fencing 计数器（Lua 脚本保证原子性）
 	// NX: 只有 key 不存在时才设置
-	// EX: 设置过期时间，防止死锁（持有锁的进程崩溃时，锁会自动释放）
-	success, err := l.client.SetNX(ctx, l.key, l.value, l.expiration).Result()
+	// PX: 设置过期时间，防止死锁（持有锁的进程崩溃时，锁会自动释放）
+	token, err := acquireScript.Run(ctx, l.client,
+		[]string{l.key, fencingCounterKey}, l.value, l.expiration.Milliseconds()).Int64()
 	if err != nil {
 		return false, err
 	}
-	return success, nil
+	if token == 0 {
+		return false, nil
+	}
+	l.token = token
+	return true, nil
 }
 
-// Lock 阻塞式获取锁（带重试）
-func (l *DistributedLock) Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) error {
-	for i := 0; i < maxRetries; i++ {
-		success, err := l.TryLock(ctx)
-		if err != nil {
-			return err
-		}
-		if success {
-			return nil
-		}
-		// 等待一段时间后重试
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(retryInterval):
-			// 继续重试
-		}
+// Lock 阻塞式获取锁（带重试），返回本次加锁的 fencing token
+//
+// 公平锁不轮询，按 retryInterval*maxRetries 作为最长等待时间排队等待通知
+func (l *DistributedLock) Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) (int64, error) {
+	if l.fair {
+		return l.lockFairWithin(ctx, retryInterval*time.Duration(maxRetries))
 	}
-	return ErrLockFailed
+
+	if err := retryLock(ctx, l.TryLock, retryInterval, maxRetries); err != nil {
+		return 0, err
+	}
+	return l.token, nil
+}
+
+// Token 返回最近一次加锁获得的 fencing token，未加锁时为 0
+func (l *DistributedLock) Token() int64 {
+	return l.token
+}
+
+// LockInTx Redis 锁与数据库事务无关，空操作（实现 Lock 接口）
+func (l *DistributedLock) LockInTx(ctx context.Context, tx *gorm.DB) error {
+	return nil
 }
 
 // Unlock 释放锁
@@ -109,19 +158,44 @@ func (l *DistributedLock) Lock(ctx context.Context, retryInterval time.Duration,
 //	使用 value 验证后：
 //	A 的 Unlock 发现 value 不是自己的，不会删除，B 的锁安全
 func (l *DistributedLock) Unlock(ctx context.Context) error {
-	// Lua 脚本：检查 value 是否匹配，匹配则删除
-	// 使用 Lua 脚本保证原子性，避免"检查-删除"之间的并发问题
-	script := `
-		if redis.call("GET", KEYS[1]) == ARGV[1] then
-			return redis.call("DEL", KEYS[1])
-		else
-			return 0
-		end
-	`
-	_, err := l.client.Eval(ctx, script, []string{l.key}, l.value).Result()
+	l.watchdog.stopAndWait()
+
+	_, err := unlockScript.Run(ctx, l.client, []string{l.key}, l.value, releaseChannel(l.key)).Result()
 	return err
 }
 
+// unlockScript 检查 value 是否匹配，匹配则删除，并通知等待者（见 fair_lock.go）
+// 使用 Lua 脚本保证原子性，避免"检查-删除"之间的并发问题
+var unlockScript = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		redis.call("DEL", KEYS[1])
+		redis.call("PUBLISH", ARGV[2], ARGV[1])
+		return 1
+	else
+		return 0
+	end
+`)
+
+// renewScript 续期：value 匹配才续期，保证只续自己的锁（看门狗原理见 watchdog.go）
+var renewScript = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	else
+		return 0
+	end
+`)
+
+// StartWatchdog 启动看门狗，必须在获取锁成功后调用（可选）
+//
+// 返回的 context 派生自 ctx，锁丢失时被取消，context.Cause 返回 ErrLockLost。
+// 调用方应使用返回的 context 执行受锁保护的操作。看门狗在 Unlock 时停止。
+func (l *DistributedLock) StartWatchdog(ctx context.Context) context.Context {
+	return l.watchdog.start(ctx, l.key, l.expiration, func(ctx context.Context) (bool, error) {
+		result, err := renewScript.Run(ctx, l.client, []string{l.key}, l.value, l.expiration.Milliseconds()).Int()
+		return result == 1, err
+	})
+}
+
 // ============================================================================
 // 便捷函数：基于用户ID的支付锁
 // ============================================================================
@@ -141,7 +215,42 @@ func (l *DistributedLock) Unlock(ctx context.Context) error {
 // 方案3：按账户+金额加锁（更细粒度）
 //   - 在热点账户场景下使用，当前项目暂不需要
 func NewPayLock(client *redis.Client, userID int64, requestID string) *DistributedLock {
-	key := fmt.Sprintf("pay:lock:user:%d", userID)
 	// value 使用 requestID，便于追踪是哪个请求持有锁
-	return NewDistributedLock(client, key, requestID, 30*time.Second)
+	return NewDistributedLock(client, PayLockKey(userID), requestID, 30*time.Second)
+}
+
+// PayLockKey 支付锁的 key（按用户维度）
+func PayLockKey(userID int64) string {
+	return fmt.Sprintf("pay:lock:user:%d", userID)
+}
+
+// RefundLockKey 退款锁的 key（按订单维度）
+func RefundLockKey(orderNo string) string {
+	return fmt.Sprintf("refund:lock:order:%s", orderNo)
+}
+
+// AdminUserLockKey 管理后台操作用户的可重入锁 key（Hash 结构，不能与 PayLockKey 共用）
+func AdminUserLockKey(userID int64) string {
+	return fmt.Sprintf("admin:lock:user:%d", userID)
+}
+
+// retryLock 按固定间隔重试 tryLock，各种锁共用
+func retryLock(ctx context.Context, tryLock func(ctx context.Context) (bool, error), retryInterval time.Duration, maxRetries int) error {
+	for i := 0; i < maxRetries; i++ {
+		success, err := tryLock(ctx)
+		if err != nil {
+			return err
+		}
+		if success {
+			return nil
+		}
+		// 等待一段时间后重试
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryInterval):
+			// 继续重试
+		}
+	}
+	return ErrLockFailed
 }
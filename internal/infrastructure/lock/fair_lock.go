@@ -0,0 +1,169 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ============================================================================
+// 公平锁（排队 + 释放通知）
+// ============================================================================
+//
+// 【轮询等待的问题】
+//
+// Lock 每 100ms 尝试一次 SETNX，最多 30 次。热点用户并发支付时：
+//   - 大量无效的 Redis 请求（每个等待者每秒 10 次）
+//   - 没有公平性：锁释放的瞬间谁恰好在轮询谁拿到，先来的请求可能一直抢不到
+//
+// 【公平锁】
+//
+//   <key>:queue     ZSET  等待队列，score 为入队时间（Redis 服务器时间，保证先来先得）
+//   <key>:timeout   ZSET  等待者心跳，score 为过期时间，等待者崩溃后会被清理出队列
+//   <key>:released  频道  锁释放时发布通知
+//
+//   加锁（Lua 原子执行）：
+//     1. 清理心跳过期的等待者
+//     2. 锁空闲，且自己是队首（或队列为空）-> 加锁成功，出队
+//     3. 否则入队（已在队中则保持原来的位置），刷新心跳
+//
+//   等待：订阅释放频道，收到通知后再尝试加锁；同时每隔 checkInterval 主动尝试一次，
+//   处理持有者崩溃导致锁过期（没有释放通知）的情况，也顺便刷新心跳。
+//
+//   放弃等待（ctx 取消/超时）：主动出队，不阻塞后面的等待者。
+//
+// ============================================================================
+
+const (
+	fairCheckInterval = time.Second           // 没有收到通知时的主动检查间隔
+	fairWaiterTTL     = 3 * fairCheckInterval // 等待者心跳过期时间
+)
+
+// 脚本中先调用 TIME 再写入，需要 Redis 5+（或 replicate_commands）的效果复制
+var fairAcquireScript = redis.NewScript(`
+	redis.replicate_commands()
+	local now = redis.call("TIME")
+	local nowMs = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+
+	local stale = redis.call("ZRANGEBYSCORE", KEYS[3], "-inf", nowMs)
+	for _, waiter in ipairs(stale) do
+		redis.call("ZREM", KEYS[2], waiter)
+		redis.call("ZREM", KEYS[3], waiter)
+	end
+
+	if redis.call("EXISTS", KEYS[1]) == 0 then
+		local head = redis.call("ZRANGE", KEYS[2], 0, 0)
+		if #head == 0 or head[1] == ARGV[1] then
+			redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+			redis.call("ZREM", KEYS[2], ARGV[1])
+			redis.call("ZREM", KEYS[3], ARGV[1])
+			return redis.call("INCR", KEYS[4])
+		end
+	end
+
+	redis.call("ZADD", KEYS[2], "NX", nowMs, ARGV[1])
+	redis.call("ZADD", KEYS[3], nowMs + tonumber(ARGV[3]), ARGV[1])
+	redis.call("PEXPIRE", KEYS[2], ARGV[3] * 2)
+	redis.call("PEXPIRE", KEYS[3], ARGV[3] * 2)
+	return 0
+`)
+
+var fairLeaveScript = redis.NewScript(`
+	redis.call("ZREM", KEYS[1], ARGV[1])
+	redis.call("ZREM", KEYS[2], ARGV[1])
+	return 1
+`)
+
+// NewFairLock 创建公平锁，Lock 时排队等待释放通知
+func NewFairLock(client *redis.Client, key, value string, expiration time.Duration) *DistributedLock {
+	l := NewDistributedLock(client, key, value, expiration)
+	l.fair = true
+	return l
+}
+
+func releaseChannel(key string) string {
+	return key + ":released"
+}
+
+func (l *DistributedLock) queueKey() string {
+	return l.key + ":queue"
+}
+
+func (l *DistributedLock) timeoutKey() string {
+	return l.key + ":timeout"
+}
+
+// LockFair 排队等待获取锁，直到成功或 ctx 结束，返回 fencing token
+func (l *DistributedLock) LockFair(ctx context.Context) (int64, error) {
+	// 无竞争时直接拿到锁，不需要订阅（订阅会单独占用一个 Redis 连接）
+	token, err := l.tryLockFair(ctx)
+	if err != nil {
+		l.leaveQueue()
+		return 0, err
+	}
+	if token > 0 {
+		l.token = token
+		return token, nil
+	}
+
+	// 拿不到才订阅释放通知；订阅后下面的循环会先再试一次，
+	// 避免"尝试失败 -> 锁释放 -> 才开始订阅"漏掉通知
+	pubsub := l.client.Subscribe(ctx, releaseChannel(l.key))
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		l.leaveQueue()
+		return 0, err
+	}
+	released := pubsub.Channel()
+
+	ticker := time.NewTicker(fairCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		token, err := l.tryLockFair(ctx)
+		if err != nil {
+			l.leaveQueue()
+			return 0, err
+		}
+		if token > 0 {
+			l.token = token
+			return token, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			l.leaveQueue()
+			return 0, ctx.Err()
+		case <-released:
+		case <-ticker.C:
+		}
+	}
+}
+
+// lockFairWithin 最多等待 maxWait，超时返回 ErrLockFailed（与轮询方式保持一致）
+func (l *DistributedLock) lockFairWithin(ctx context.Context, maxWait time.Duration) (int64, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	token, err := l.LockFair(waitCtx)
+	// 等待超时（而不是调用方 ctx 结束）视为锁竞争失败
+	if err != nil && ctx.Err() == nil && errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+		return 0, ErrLockFailed
+	}
+	return token, err
+}
+
+func (l *DistributedLock) tryLockFair(ctx context.Context) (int64, error) {
+	keys := []string{l.key, l.queueKey(), l.timeoutKey(), fencingCounterKey}
+	return fairAcquireScript.Run(ctx, l.client, keys,
+		l.value, l.expiration.Milliseconds(), fairWaiterTTL.Milliseconds()).Int64()
+}
+
+// leaveQueue 放弃等待时出队，ctx 可能已取消，使用独立的超时
+func (l *DistributedLock) leaveQueue() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	fairLeaveScript.Run(ctx, l.client, []string{l.queueKey(), l.timeoutKey()}, l.value)
+}
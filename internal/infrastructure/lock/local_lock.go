@@ -0,0 +1,99 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LocalLocker 进程内互斥锁，只在单实例部署或测试时使用
+//
+// 进程退出锁自然释放，因此不需要过期时间，也不需要续期。
+type LocalLocker struct {
+	mu    sync.Mutex
+	locks map[string]*localEntry
+}
+
+type localEntry struct {
+	ch   chan struct{} // 容量为 1，写入成功即持有锁
+	refs int           // 持有或等待该锁的数量，为 0 时从 map 中删除
+}
+
+func NewLocalLocker() *LocalLocker {
+	return &LocalLocker{locks: make(map[string]*localEntry)}
+}
+
+func (m *LocalLocker) Name() string {
+	return ProviderLocal
+}
+
+func (m *LocalLocker) NewLock(res Resource, owner string, expiration time.Duration) Lock {
+	return &localLock{locker: m, key: res.Key}
+}
+
+func (m *LocalLocker) acquireEntry(key string) *localEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.locks[key]
+	if !ok {
+		e = &localEntry{ch: make(chan struct{}, 1)}
+		m.locks[key] = e
+	}
+	e.refs++
+	return e
+}
+
+func (m *LocalLocker) releaseEntry(key string, e *localEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.refs--
+	if e.refs == 0 {
+		delete(m.locks, key)
+	}
+}
+
+type localLock struct {
+	locker *LocalLocker
+	key    string
+	entry  *localEntry
+}
+
+func (l *localLock) Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) (int64, error) {
+	e := l.locker.acquireEntry(l.key)
+
+	// 与 Redis 实现保持一致的最长等待时间
+	timer := time.NewTimer(retryInterval * time.Duration(maxRetries))
+	defer timer.Stop()
+
+	select {
+	case e.ch <- struct{}{}:
+		l.entry = e
+		return 0, nil
+	case <-ctx.Done():
+		l.locker.releaseEntry(l.key, e)
+		return 0, ctx.Err()
+	case <-timer.C:
+		l.locker.releaseEntry(l.key, e)
+		return 0, ErrLockFailed
+	}
+}
+
+func (l *localLock) StartWatchdog(ctx context.Context) context.Context {
+	return ctx
+}
+
+func (l *localLock) LockInTx(ctx context.Context, tx *gorm.DB) error {
+	return nil
+}
+
+func (l *localLock) Unlock(ctx context.Context) error {
+	if l.entry == nil {
+		return nil
+	}
+	<-l.entry.ch
+	l.locker.releaseEntry(l.key, l.entry)
+	l.entry = nil
+	return nil
+}
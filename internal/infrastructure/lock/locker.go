@@ -0,0 +1,261 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/logging"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// ============================================================================
+// 锁提供者抽象
+// ============================================================================
+//
+// 【为什么要抽象？】
+//
+// 之前 PayService/RefundService 直接依赖 Redis 分布式锁，Redis 一挂就完全无法支付。
+// 现在业务只依赖 Locker 接口，具体实现可以按配置切换：
+//
+//   - redis: Redis 分布式锁（默认），多实例部署使用
+//   - redlock: 多个独立 Redis 节点上的 Redlock，Redis 主从切换也不会丢锁
+//   - mysql: 在业务事务内 SELECT ... FOR UPDATE 锁住业务行，Redis 不可用时的降级方案
+//   - local: 进程内互斥锁，单机部署或测试使用
+//
+// 配置了 fallback 时，Redis 连续出错达到阈值后熔断，熔断期间直接使用降级实现，
+// 熔断时间过后再尝试 Redis。
+//
+// 【降级期间的正确性】
+//
+// 降级时可能出现部分请求持有 Redis 锁、部分请求持有 MySQL 行锁的情况，互斥被削弱。
+// 但余额扣减本身有乐观锁（version）和 balance >= amount 条件兜底，
+// 订单有 request_id 唯一索引兜底，不会出现超扣和重复下单。
+//
+// ============================================================================
+
+var lockerLog = logging.For("Locker")
+
+// Redis 锁的等待方式
+const (
+	WaitModePoll = "poll" // 固定间隔轮询 SETNX
+	WaitModeFair = "fair" // 排队等待释放通知，先到先得
+)
+
+const (
+	ProviderRedis   = "redis"
+	ProviderRedlock = "redlock"
+	ProviderMySQL   = "mysql"
+	ProviderLocal   = "local"
+)
+
+// Resource 被锁的资源
+type Resource struct {
+	Key string // 锁的 key，如 pay:lock:user:10001
+
+	// RowLock 锁住资源对应的数据库行（如 SELECT ... FOR UPDATE 账户行），
+	// 只有 MySQL 实现会在 LockInTx 中调用
+	RowLock func(ctx context.Context, tx *gorm.DB) error
+}
+
+// Lock 一把锁
+type Lock interface {
+	// Lock 阻塞式获取锁，返回 fencing token（不支持 fencing 的实现返回 0）
+	Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) (int64, error)
+	// StartWatchdog 启动自动续期，返回的 ctx 在锁丢失时取消（不需要续期的实现直接返回 ctx）
+	StartWatchdog(ctx context.Context) context.Context
+	// LockInTx 业务事务开始后调用，MySQL 实现在此加行锁，其他实现为空操作
+	LockInTx(ctx context.Context, tx *gorm.DB) error
+	// Unlock 释放锁
+	Unlock(ctx context.Context) error
+}
+
+// Locker 锁提供者
+type Locker interface {
+	Name() string
+	NewLock(res Resource, owner string, expiration time.Duration) Lock
+}
+
+// NewLocker 按配置创建锁提供者，redlockClients 为 Redlock 各节点的客户端（不使用 Redlock 时可为空）
+func NewLocker(cfg *config.LockConfig, redisClient *redis.Client, redlockClients []*redis.Client) Locker {
+	primary := newLockerByName(cfg, cfg.Provider, redisClient, redlockClients)
+	if cfg.Fallback == "" || cfg.Fallback == primary.Name() {
+		return withMetrics(primary)
+	}
+	return withMetrics(NewFallbackLocker(primary, newLockerByName(cfg, cfg.Fallback, redisClient, redlockClients),
+		cfg.FailureThreshold, time.Duration(cfg.OpenSeconds)*time.Second))
+}
+
+func newLockerByName(cfg *config.LockConfig, name string, redisClient *redis.Client, redlockClients []*redis.Client) Locker {
+	switch name {
+	case ProviderRedlock:
+		if len(redlockClients) < 3 {
+			log.Fatalf("Redlock 至少需要 3 个独立节点，当前: %d", len(redlockClients))
+		}
+		return NewRedlockLocker(redlockClients)
+	case ProviderMySQL:
+		return NewMySQLLocker()
+	case ProviderLocal:
+		return NewLocalLocker()
+	case ProviderRedis, "":
+		return NewRedisLocker(redisClient, cfg.WaitMode == WaitModeFair)
+	default:
+		log.Fatalf("未知的锁实现: %s", name)
+		return nil
+	}
+}
+
+// ============================================================================
+// Redis 实现
+// ============================================================================
+
+// RedisLocker 基于 DistributedLock 的锁提供者
+type RedisLocker struct {
+	client *redis.Client
+	fair   bool // 使用公平锁（排队等待释放通知）代替轮询
+}
+
+func NewRedisLocker(client *redis.Client, fair bool) *RedisLocker {
+	return &RedisLocker{client: client, fair: fair}
+}
+
+func (r *RedisLocker) Name() string {
+	return ProviderRedis
+}
+
+func (r *RedisLocker) NewLock(res Resource, owner string, expiration time.Duration) Lock {
+	if r.fair {
+		return NewFairLock(r.client, res.Key, owner, expiration)
+	}
+	return NewDistributedLock(r.client, res.Key, owner, expiration)
+}
+
+// ============================================================================
+// 熔断降级
+// ============================================================================
+
+// FallbackLocker 主实现连续出错时熔断，切换到降级实现
+type FallbackLocker struct {
+	primary      Locker
+	fallback     Locker
+	threshold    int
+	openDuration time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func NewFallbackLocker(primary, fallback Locker, threshold int, openDuration time.Duration) *FallbackLocker {
+	if threshold <= 0 {
+		threshold = 3
+	}
+	if openDuration <= 0 {
+		openDuration = 30 * time.Second
+	}
+	return &FallbackLocker{
+		primary:      primary,
+		fallback:     fallback,
+		threshold:    threshold,
+		openDuration: openDuration,
+	}
+}
+
+func (f *FallbackLocker) Name() string {
+	return f.primary.Name() + "+" + f.fallback.Name()
+}
+
+func (f *FallbackLocker) NewLock(res Resource, owner string, expiration time.Duration) Lock {
+	return &fallbackLock{
+		locker:     f,
+		res:        res,
+		owner:      owner,
+		expiration: expiration,
+	}
+}
+
+// Healthy 主实现是否可用（未熔断）
+func (f *FallbackLocker) Healthy() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return time.Now().After(f.openUntil)
+}
+
+func (f *FallbackLocker) recordSuccess() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = 0
+}
+
+func (f *FallbackLocker) recordFailure(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures++
+	if f.failures >= f.threshold {
+		f.openUntil = time.Now().Add(f.openDuration)
+		f.failures = 0
+		lockerLog.Warn("主实现连续失败，熔断并降级", "primary", f.primary.Name(),
+			"open_duration", f.openDuration, "fallback", f.fallback.Name(), "err", err)
+	}
+}
+
+type fallbackLock struct {
+	locker     *FallbackLocker
+	res        Resource
+	owner      string
+	expiration time.Duration
+	active     Lock // 实际生效的锁
+}
+
+func (l *fallbackLock) Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) (int64, error) {
+	if l.locker.Healthy() {
+		primary := l.locker.primary.NewLock(l.res, l.owner, l.expiration)
+		token, err := primary.Lock(ctx, retryInterval, maxRetries)
+		if err == nil {
+			l.locker.recordSuccess()
+			l.active = primary
+			return token, nil
+		}
+		// 锁竞争失败、ctx 取消不是主实现故障，不降级
+		if errors.Is(err, ErrLockFailed) || ctx.Err() != nil {
+			return 0, err
+		}
+		l.locker.recordFailure(err)
+		lockerLog.WarnContext(ctx, "加锁出错，本次降级", "primary", l.locker.primary.Name(),
+			"fallback", l.locker.fallback.Name(), "key", l.res.Key, "err", err)
+	}
+
+	fallback := l.locker.fallback.NewLock(l.res, l.owner, l.expiration)
+	token, err := fallback.Lock(ctx, retryInterval, maxRetries)
+	if err != nil {
+		return 0, err
+	}
+	l.active = fallback
+	return token, nil
+}
+
+func (l *fallbackLock) StartWatchdog(ctx context.Context) context.Context {
+	if l.active == nil {
+		return ctx
+	}
+	return l.active.StartWatchdog(ctx)
+}
+
+func (l *fallbackLock) LockInTx(ctx context.Context, tx *gorm.DB) error {
+	if l.active == nil {
+		return nil
+	}
+	return l.active.LockInTx(ctx, tx)
+}
+
+func (l *fallbackLock) Unlock(ctx context.Context) error {
+	if l.active == nil {
+		return nil
+	}
+	return l.active.Unlock(ctx)
+}
@@ -0,0 +1,53 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"paysystem/internal/infrastructure/metrics"
+
+	"gorm.io/gorm"
+)
+
+// instrumentedLocker 记录加锁等待耗时和失败次数，对所有锁实现生效
+type instrumentedLocker struct {
+	Locker
+}
+
+func withMetrics(locker Locker) Locker {
+	return &instrumentedLocker{Locker: locker}
+}
+
+func (l *instrumentedLocker) NewLock(res Resource, owner string, expiration time.Duration) Lock {
+	return &instrumentedLock{inner: l.Locker.NewLock(res, owner, expiration), provider: l.Name()}
+}
+
+type instrumentedLock struct {
+	inner    Lock
+	provider string
+}
+
+func (l *instrumentedLock) Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) (int64, error) {
+	start := time.Now()
+	token, err := l.inner.Lock(ctx, retryInterval, maxRetries)
+
+	result := metrics.ResultSuccess
+	if err != nil {
+		result = metrics.ResultFailure
+		metrics.LockFailures.WithLabelValues(l.provider).Inc()
+	}
+	metrics.LockWait.WithLabelValues(l.provider, result).Observe(time.Since(start).Seconds())
+	return token, err
+}
+
+func (l *instrumentedLock) StartWatchdog(ctx context.Context) context.Context {
+	return l.inner.StartWatchdog(ctx)
+}
+
+func (l *instrumentedLock) LockInTx(ctx context.Context, tx *gorm.DB) error {
+	return l.inner.LockInTx(ctx, tx)
+}
+
+func (l *instrumentedLock) Unlock(ctx context.Context) error {
+	return l.inner.Unlock(ctx)
+}
@@ -0,0 +1,50 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MySQLLocker 基于数据库行锁的锁提供者
+//
+// 锁的生命周期和业务事务绑定：LockInTx 时执行 SELECT ... FOR UPDATE，事务提交/回滚时释放。
+// Lock/Unlock 都是空操作，因此加锁之后、事务开始之前的读操作不受保护，
+// 业务需要在事务内以行锁读到的数据为准。
+type MySQLLocker struct{}
+
+func NewMySQLLocker() *MySQLLocker {
+	return &MySQLLocker{}
+}
+
+func (m *MySQLLocker) Name() string {
+	return ProviderMySQL
+}
+
+func (m *MySQLLocker) NewLock(res Resource, owner string, expiration time.Duration) Lock {
+	return &mysqlLock{res: res}
+}
+
+type mysqlLock struct {
+	res Resource
+}
+
+func (l *mysqlLock) Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) (int64, error) {
+	return 0, nil
+}
+
+func (l *mysqlLock) StartWatchdog(ctx context.Context) context.Context {
+	return ctx
+}
+
+func (l *mysqlLock) LockInTx(ctx context.Context, tx *gorm.DB) error {
+	if l.res.RowLock == nil {
+		return nil
+	}
+	return l.res.RowLock(ctx, tx)
+}
+
+func (l *mysqlLock) Unlock(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,219 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// ============================================================================
+// Redlock（多节点分布式锁）
+// ============================================================================
+//
+// 【主从切换丢锁】
+//
+//   A 在主节点 SETNX 成功 -> 主节点还没把 key 同步给从节点就挂了
+//   -> 从节点升级为主节点（没有这把锁）-> B 也加锁成功，两人同时持有锁
+//
+// 【Redlock】
+//
+// 使用 N 个相互独立的 Redis 主节点（没有主从关系），在每个节点上用同样的 key/value 加锁：
+//   1. 记录开始时间，依次（并发）向 N 个节点加锁，每个节点的请求超时远小于锁的过期时间
+//   2. 计算有效时间：validity = 过期时间 - 加锁耗时 - 时钟漂移
+//   3. 成功节点数 >= N/2+1 且 validity > 0 才算加锁成功
+//   4. 加锁失败时在所有节点上释放（包括看起来失败的节点，它可能其实加成功了）
+//
+// 时钟漂移：各节点时钟走速不同，按过期时间的 1% 加 2ms 估算。
+//
+// 【注意】Redlock 各节点的计数器相互独立，无法给出全局单调的 fencing token，Lock 返回 0。
+//
+// ============================================================================
+
+const (
+	redlockDriftFactor = 0.01
+	redlockDriftBase   = 2 * time.Millisecond
+)
+
+// Redlock 多节点分布式锁
+type Redlock struct {
+	clients    []*redis.Client
+	key        string
+	value      string
+	expiration time.Duration
+	quorum     int
+
+	validUntil atomic.Int64 // 本次加锁的有效截止时间（UnixNano），看门狗续期时会更新
+
+	watchdog watchdog
+}
+
+// NewRedlock 创建 Redlock
+func NewRedlock(clients []*redis.Client, key, value string, expiration time.Duration) *Redlock {
+	return &Redlock{
+		clients:    clients,
+		key:        key,
+		value:      value,
+		expiration: expiration,
+		quorum:     len(clients)/2 + 1,
+	}
+}
+
+// nodeTimeout 单个节点的请求超时，远小于锁的过期时间，避免在挂掉的节点上等太久
+func (l *Redlock) nodeTimeout() time.Duration {
+	timeout := l.expiration / 100
+	if timeout < 50*time.Millisecond {
+		timeout = 50 * time.Millisecond
+	}
+	return timeout
+}
+
+// nodeResult 在所有节点上执行一次操作的结果
+type nodeResult struct {
+	success int   // 执行成功的节点数
+	failed  int   // 出错（连接失败、超时等）的节点数，不含正常返回 false 的节点
+	lastErr error // 最后一个出错节点的错误
+}
+
+// forEachNode 并发在所有节点上执行 fn，分别统计成功和出错的节点数
+func (l *Redlock) forEachNode(ctx context.Context, fn func(ctx context.Context, client *redis.Client) (bool, error)) nodeResult {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		result nodeResult
+	)
+	for _, client := range l.clients {
+		wg.Add(1)
+		go func(client *redis.Client) {
+			defer wg.Done()
+			nodeCtx, cancel := context.WithTimeout(ctx, l.nodeTimeout())
+			defer cancel()
+			ok, err := fn(nodeCtx, client)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				result.failed++
+				result.lastErr = err
+			case ok:
+				result.success++
+			}
+		}(client)
+	}
+	wg.Wait()
+	return result
+}
+
+// unavailable 正常应答的节点不足多数时返回错误：这是节点故障而不是锁竞争，
+// 不能返回 ErrLockFailed，否则 FallbackLocker 不会熔断降级
+func (l *Redlock) unavailable(result nodeResult) error {
+	if answered := len(l.clients) - result.failed; answered < l.quorum {
+		return fmt.Errorf("Redlock 可用节点不足: %d/%d, 需要 %d: %w", answered, len(l.clients), l.quorum, result.lastErr)
+	}
+	return nil
+}
+
+// TryLock 尝试获取锁（非阻塞）
+func (l *Redlock) TryLock(ctx context.Context) (bool, error) {
+	if len(l.clients) == 0 {
+		return false, ErrLockFailed
+	}
+
+	start := time.Now()
+	result := l.forEachNode(ctx, func(ctx context.Context, client *redis.Client) (bool, error) {
+		return client.SetNX(ctx, l.key, l.value, l.expiration).Result()
+	})
+
+	drift := time.Duration(float64(l.expiration)*redlockDriftFactor) + redlockDriftBase
+	validity := l.expiration - time.Since(start) - drift
+
+	if result.success >= l.quorum && validity > 0 {
+		l.validUntil.Store(start.Add(validity).UnixNano())
+		return true, nil
+	}
+
+	// 未达到多数，在所有节点上释放
+	l.release(ctx)
+	if err := l.unavailable(result); err != nil {
+		return false, err
+	}
+	return false, ctx.Err()
+}
+
+// Lock 阻塞式获取锁（带重试），Redlock 不提供 fencing token，返回 0
+func (l *Redlock) Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) (int64, error) {
+	return 0, retryLock(ctx, l.TryLock, retryInterval, maxRetries)
+}
+
+// ValidUntil 本次加锁的有效截止时间（已扣除加锁耗时和时钟漂移），
+// 启动看门狗后会随续期延长，未启动看门狗时业务应在此之前完成
+func (l *Redlock) ValidUntil() time.Time {
+	return time.Unix(0, l.validUntil.Load())
+}
+
+// StartWatchdog 启动看门狗，多数节点续期成功才算续期成功
+func (l *Redlock) StartWatchdog(ctx context.Context) context.Context {
+	return l.watchdog.start(ctx, l.key, l.expiration, func(ctx context.Context) (bool, error) {
+		start := time.Now()
+		result := l.forEachNode(ctx, func(ctx context.Context, client *redis.Client) (bool, error) {
+			renewed, err := renewScript.Run(ctx, client, []string{l.key}, l.value, l.expiration.Milliseconds()).Int()
+			return renewed == 1, err
+		})
+		if result.success < l.quorum {
+			// 节点故障时返回错误，看门狗会继续重试直到锁过期；多数节点明确答复不是自己的锁才算丢失
+			return false, l.unavailable(result)
+		}
+		drift := time.Duration(float64(l.expiration)*redlockDriftFactor) + redlockDriftBase
+		l.validUntil.Store(start.Add(l.expiration - time.Since(start) - drift).UnixNano())
+		return true, nil
+	})
+}
+
+// LockInTx Redis 锁与数据库事务无关，空操作（实现 Lock 接口）
+func (l *Redlock) LockInTx(ctx context.Context, tx *gorm.DB) error {
+	return nil
+}
+
+// Unlock 在所有节点上释放锁
+func (l *Redlock) Unlock(ctx context.Context) error {
+	l.watchdog.stopAndWait()
+	l.release(ctx)
+	return nil
+}
+
+// release 在所有节点上释放（只删除 value 是自己的 key）
+func (l *Redlock) release(ctx context.Context) {
+	// 调用方 ctx 可能已取消，释放使用独立的 ctx，保证尽量释放干净
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), l.nodeTimeout()*2)
+	defer cancel()
+
+	l.forEachNode(releaseCtx, func(ctx context.Context, client *redis.Client) (bool, error) {
+		result, err := unlockScript.Run(ctx, client, []string{l.key}, l.value, releaseChannel(l.key)).Int()
+		return result == 1, err
+	})
+}
+
+// ============================================================================
+// Locker 实现
+// ============================================================================
+
+// RedlockLocker 基于 Redlock 的锁提供者
+type RedlockLocker struct {
+	clients []*redis.Client
+}
+
+func NewRedlockLocker(clients []*redis.Client) *RedlockLocker {
+	return &RedlockLocker{clients: clients}
+}
+
+func (r *RedlockLocker) Name() string {
+	return ProviderRedlock
+}
+
+func (r *RedlockLocker) NewLock(res Resource, owner string, expiration time.Duration) Lock {
+	return NewRedlock(r.clients, res.Key, owner, expiration)
+}
@@ -0,0 +1,165 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// ============================================================================
+// 可重入锁
+// ============================================================================
+//
+// 【为什么需要可重入？】
+//
+// DistributedLock 是一个简单的 SETNX，同一个持有者再次加锁也会失败：
+//
+//   管理后台：为用户A退款多笔订单并调整余额
+//     获取 user:A 的锁
+//       -> 调用退款服务，内部再次获取 user:A 的锁  <-- 拿不到，死锁（直到超时）
+//
+// 可重入锁用 Redis Hash 记录"持有者 -> 持有次数"：
+//   - 锁不存在，或持有者是自己：持有次数 +1，加锁成功
+//   - 释放时持有次数 -1，减到 0 才真正删除 key
+//
+// 【持有者标识】
+//
+// 嵌套调用之间通过 context 传递持有者（WithOwner），内层加锁时优先使用 ctx 中的持有者，
+// 这样外层和内层就是"同一个持有者"。
+//
+// 【注意】可重入锁使用 Hash 结构，不能和 DistributedLock（String 结构）使用同一个 key。
+//
+// ============================================================================
+
+// reentrantAcquireScript 加锁：返回加锁后的持有次数，失败返回 0
+var reentrantAcquireScript = redis.NewScript(`
+	if redis.call("EXISTS", KEYS[1]) == 0 or redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+		local count = redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		return count
+	end
+	return 0
+`)
+
+// reentrantReleaseScript 释放：返回释放后的持有次数，不是自己的锁返回 -1
+var reentrantReleaseScript = redis.NewScript(`
+	if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 0 then
+		return -1
+	end
+	local count = redis.call("HINCRBY", KEYS[1], ARGV[1], -1)
+	if count > 0 then
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		return count
+	end
+	redis.call("HDEL", KEYS[1], ARGV[1])
+	if redis.call("HLEN", KEYS[1]) == 0 then
+		redis.call("DEL", KEYS[1])
+	end
+	return 0
+`)
+
+// hashRenewScript 续期：持有者仍在 Hash 中才续期（可重入锁、读写锁共用）
+var hashRenewScript = redis.NewScript(`
+	if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+		return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	end
+	return 0
+`)
+
+type ownerContextKey struct{}
+
+// WithOwner 将锁持有者标识放入 ctx，嵌套调用中的可重入锁、读写锁都以它作为持有者
+func WithOwner(ctx context.Context, owner string) context.Context {
+	return context.WithValue(ctx, ownerContextKey{}, owner)
+}
+
+// OwnerFromContext 从 ctx 中取出锁持有者标识
+func OwnerFromContext(ctx context.Context) (string, bool) {
+	owner, ok := ctx.Value(ownerContextKey{}).(string)
+	return owner, ok && owner != ""
+}
+
+// resolveOwner ctx 中有持有者时优先使用，否则使用创建锁时指定的持有者
+func resolveOwner(ctx context.Context, owner string) string {
+	if ctxOwner, ok := OwnerFromContext(ctx); ok {
+		return ctxOwner
+	}
+	return owner
+}
+
+// ReentrantLock 可重入分布式锁
+type ReentrantLock struct {
+	client     *redis.Client
+	key        string
+	owner      string // 创建时指定的持有者，ctx 中没有持有者时使用
+	expiration time.Duration
+
+	heldBy    string // 实际加锁使用的持有者
+	holdCount int64  // 加锁后的持有次数
+
+	watchdog watchdog
+}
+
+// NewReentrantLock 创建可重入锁
+func NewReentrantLock(client *redis.Client, key, owner string, expiration time.Duration) *ReentrantLock {
+	return &ReentrantLock{
+		client:     client,
+		key:        key,
+		owner:      owner,
+		expiration: expiration,
+	}
+}
+
+// TryLock 尝试获取锁（非阻塞）
+func (l *ReentrantLock) TryLock(ctx context.Context) (bool, error) {
+	owner := resolveOwner(ctx, l.owner)
+	count, err := reentrantAcquireScript.Run(ctx, l.client, []string{l.key}, owner, l.expiration.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	if count == 0 {
+		return false, nil
+	}
+	l.heldBy = owner
+	l.holdCount = count
+	return true, nil
+}
+
+// Lock 阻塞式获取锁（带重试），可重入锁不提供 fencing token，返回 0
+func (l *ReentrantLock) Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) (int64, error) {
+	return 0, retryLock(ctx, l.TryLock, retryInterval, maxRetries)
+}
+
+// HoldCount 加锁后当前持有者的持有次数（嵌套层数）
+func (l *ReentrantLock) HoldCount() int64 {
+	return l.holdCount
+}
+
+// StartWatchdog 启动看门狗，语义同 DistributedLock.StartWatchdog
+func (l *ReentrantLock) StartWatchdog(ctx context.Context) context.Context {
+	owner := l.heldBy
+	return l.watchdog.start(ctx, l.key, l.expiration, func(ctx context.Context) (bool, error) {
+		result, err := hashRenewScript.Run(ctx, l.client, []string{l.key}, owner, l.expiration.Milliseconds()).Int()
+		return result == 1, err
+	})
+}
+
+// LockInTx Redis 锁与数据库事务无关，空操作（实现 Lock 接口）
+func (l *ReentrantLock) LockInTx(ctx context.Context, tx *gorm.DB) error {
+	return nil
+}
+
+// Unlock 释放一层持有，持有次数减到 0 时删除锁
+func (l *ReentrantLock) Unlock(ctx context.Context) error {
+	l.watchdog.stopAndWait()
+
+	if l.heldBy == "" {
+		return nil
+	}
+	_, err := reentrantReleaseScript.Run(ctx, l.client, []string{l.key}, l.heldBy, l.expiration.Milliseconds()).Result()
+	l.heldBy = ""
+	l.holdCount = 0
+	return err
+}
@@ -0,0 +1,165 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// ============================================================================
+// 读写锁
+// ============================================================================
+//
+// 读多写少的场景（如管理后台批量查询用户订单、对账时读取余额快照），
+// 读和读之间不需要互斥，只有写需要独占。
+//
+// 数据结构：Redis Hash
+//   __mode   -> read / write      当前锁模式
+//   <持有者> -> 持有次数           每个持有者的重入次数
+//
+// 规则：
+//   - 读锁：无锁或当前为读模式时可加；写锁持有者也可以再加读锁（锁降级）
+//   - 写锁：无锁时可加；写锁持有者可重入
+//   - 读锁持有者不能直接升级为写锁（两个读者同时升级会互相等待）
+//   - 锁降级后，直到写锁持有者全部释放，模式才会结束，期间其他读者仍需等待
+//
+// 持有者标识同可重入锁，优先使用 ctx 中的持有者（WithOwner）。
+//
+// ============================================================================
+
+const rwModeField = "__mode"
+
+var readAcquireScript = redis.NewScript(`
+	local mode = redis.call("HGET", KEYS[1], "` + rwModeField + `")
+	if not mode then
+		redis.call("HSET", KEYS[1], "` + rwModeField + `", "read")
+		redis.call("HSET", KEYS[1], ARGV[1], 1)
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		return 1
+	end
+	if mode == "read" or (mode == "write" and redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1) then
+		redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		return 1
+	end
+	return 0
+`)
+
+var writeAcquireScript = redis.NewScript(`
+	local mode = redis.call("HGET", KEYS[1], "` + rwModeField + `")
+	if not mode then
+		redis.call("HSET", KEYS[1], "` + rwModeField + `", "write")
+		redis.call("HSET", KEYS[1], ARGV[1], 1)
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		return 1
+	end
+	if mode == "write" and redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+		redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		return 1
+	end
+	return 0
+`)
+
+// rwReleaseScript 释放一层持有，最后一个持有者释放时删除 key；不是自己的锁返回 -1
+var rwReleaseScript = redis.NewScript(`
+	if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 0 then
+		return -1
+	end
+	local count = redis.call("HINCRBY", KEYS[1], ARGV[1], -1)
+	if count <= 0 then
+		redis.call("HDEL", KEYS[1], ARGV[1])
+	end
+	if redis.call("HLEN", KEYS[1]) <= 1 then
+		redis.call("DEL", KEYS[1])
+		return 0
+	end
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+`)
+
+// RWLock 读写锁中的读锁或写锁
+type RWLock struct {
+	client     *redis.Client
+	key        string
+	owner      string
+	expiration time.Duration
+	write      bool
+
+	heldBy string
+
+	watchdog watchdog
+}
+
+// NewReadLock 创建读锁
+func NewReadLock(client *redis.Client, key, owner string, expiration time.Duration) *RWLock {
+	return &RWLock{
+		client:     client,
+		key:        key,
+		owner:      owner,
+		expiration: expiration,
+	}
+}
+
+// NewWriteLock 创建写锁
+func NewWriteLock(client *redis.Client, key, owner string, expiration time.Duration) *RWLock {
+	return &RWLock{
+		client:     client,
+		key:        key,
+		owner:      owner,
+		expiration: expiration,
+		write:      true,
+	}
+}
+
+// TryLock 尝试获取锁（非阻塞）
+func (l *RWLock) TryLock(ctx context.Context) (bool, error) {
+	script := readAcquireScript
+	if l.write {
+		script = writeAcquireScript
+	}
+
+	owner := resolveOwner(ctx, l.owner)
+	result, err := script.Run(ctx, l.client, []string{l.key}, owner, l.expiration.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	if result == 0 {
+		return false, nil
+	}
+	l.heldBy = owner
+	return true, nil
+}
+
+// Lock 阻塞式获取锁（带重试），读写锁不提供 fencing token，返回 0
+func (l *RWLock) Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) (int64, error) {
+	return 0, retryLock(ctx, l.TryLock, retryInterval, maxRetries)
+}
+
+// StartWatchdog 启动看门狗，语义同 DistributedLock.StartWatchdog
+func (l *RWLock) StartWatchdog(ctx context.Context) context.Context {
+	owner := l.heldBy
+	return l.watchdog.start(ctx, l.key, l.expiration, func(ctx context.Context) (bool, error) {
+		result, err := hashRenewScript.Run(ctx, l.client, []string{l.key}, owner, l.expiration.Milliseconds()).Int()
+		return result == 1, err
+	})
+}
+
+// LockInTx Redis 锁与数据库事务无关，空操作（实现 Lock 接口）
+func (l *RWLock) LockInTx(ctx context.Context, tx *gorm.DB) error {
+	return nil
+}
+
+// Unlock 释放一层持有
+func (l *RWLock) Unlock(ctx context.Context) error {
+	l.watchdog.stopAndWait()
+
+	if l.heldBy == "" {
+		return nil
+	}
+	_, err := rwReleaseScript.Run(ctx, l.client, []string{l.key}, l.heldBy, l.expiration.Milliseconds()).Result()
+	l.heldBy = ""
+	return err
+}
@@ -0,0 +1,109 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"paysystem/internal/infrastructure/logging"
+)
+
+// ============================================================================
+// 看门狗（自动续期）
+// ============================================================================
+//
+// 【为什么需要看门狗？】
+//
+// 锁的过期时间是固定的（如30秒），如果持有锁的业务执行超过30秒（比如 MySQL 事务很慢），
+// 锁会在业务执行过程中过期，另一个请求就能拿到锁，互斥被打破。
+//
+// 看门狗在持有锁期间每隔 expiration/3 续期一次，把过期时间重置为 expiration：
+//   - 进程存活：锁一直有效，直到 Unlock
+//   - 进程崩溃：看门狗随之停止，锁最多 expiration 后自动释放，不会死锁
+//
+// 续期使用 Lua 脚本"检查持有者 + PEXPIRE"，保证只续自己的锁。
+// 如果发现锁已经不是自己的（或者长时间续期失败），说明锁已丢失，
+// 通过取消返回的 context 通知调用方停止后续写操作。
+//
+// ============================================================================
+
+var watchdogLog = logging.For("DistributedLock")
+
+// renewFunc 续期一次，返回 false 表示锁已经不属于自己
+type renewFunc func(ctx context.Context) (bool, error)
+
+// watchdog 锁自动续期，各种 Redis 锁共用
+type watchdog struct {
+	mu   sync.Mutex
+	stop chan struct{} // 关闭时通知看门狗退出
+	done chan struct{} // 看门狗退出后关闭
+}
+
+// start 启动看门狗，返回的 context 在锁丢失时被取消，context.Cause 返回 ErrLockLost
+func (w *watchdog) start(ctx context.Context, key string, expiration time.Duration, renew renewFunc) context.Context {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stop != nil {
+		// 重复调用时不再启动新的看门狗
+		return ctx
+	}
+
+	watchCtx, cancel := context.WithCancelCause(ctx)
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.run(watchCtx, cancel, key, expiration, renew, w.stop, w.done)
+	return watchCtx
+}
+
+func (w *watchdog) run(ctx context.Context, cancel context.CancelCauseFunc, key string, expiration time.Duration,
+	renew renewFunc, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	interval := expiration / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastRenewed := time.Now()
+	for {
+		select {
+		case <-stop:
+			cancel(nil)
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// 续期请求不使用 ctx，避免业务 ctx 的超时影响续期判断
+			renewCtx, renewCancel := context.WithTimeout(context.Background(), interval)
+			ok, err := renew(renewCtx)
+			renewCancel()
+
+			if err == nil && ok {
+				lastRenewed = time.Now()
+				continue
+			}
+
+			// 锁已经不是自己的，或者续期持续失败直到锁过期
+			if err == nil || time.Since(lastRenewed) >= expiration {
+				watchdogLog.ErrorContext(ctx, "锁已丢失", "key", key, "err", err)
+				cancel(ErrLockLost)
+				return
+			}
+			watchdogLog.WarnContext(ctx, "锁续期失败，稍后重试", "key", key, "err", err)
+		}
+	}
+}
+
+// stopAndWait 停止看门狗并等待其退出，未启动时直接返回
+func (w *watchdog) stopAndWait() {
+	w.mu.Lock()
+	stop, done := w.stop, w.done
+	w.stop, w.done = nil, nil
+	w.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
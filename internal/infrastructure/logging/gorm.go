@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GormLogger GORM 日志：只记录出错和慢查询的 SQL，正常 SQL 不打印
+// SQL 中的参数值会被记录（排查慢查询需要），敏感字段不要以明文写入数据库
+type GormLogger struct {
+	logger        *slog.Logger
+	slowThreshold time.Duration
+}
+
+// NewGormLogger slowThreshold <= 0 时不记录慢查询
+func NewGormLogger(slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{logger: For("GORM"), slowThreshold: slowThreshold}
+}
+
+func (l *GormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	l.logger.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	l.logger.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	l.logger.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		l.logger.ErrorContext(ctx, "SQL 执行失败", "sql", sql, "rows", rows, "elapsed_ms", elapsed.Milliseconds(), "err", err)
+	case l.slowThreshold > 0 && elapsed >= l.slowThreshold:
+		sql, rows := fc()
+		l.logger.WarnContext(ctx, "慢查询", "sql", sql, "rows", rows, "elapsed_ms", elapsed.Milliseconds())
+	}
+}
@@ -0,0 +1,260 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"paysystem/internal/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ============================================================================
+// 结构化日志（log/slog）
+// ============================================================================
+//
+// 【关联字段】
+//
+// 日志里的 request_id、user_id、order_no 等字段不需要每条手动传：
+//
+//   ctx = logging.WithFields(ctx, "order_no", orderNo)   // 处理链路上加一次
+//   logger.InfoContext(ctx, "支付成功", "amount", amount) // 之后的日志自动带上
+//
+// 带 span 的 ctx 还会自动带上 trace_id、span_id，日志与链路可以互相跳转。
+// 所以记日志时尽量用 XxxContext 方法并传入请求的 ctx。
+//
+// 【脱敏】
+//
+// 键名命中敏感字段（password、secret、token、signature 等，忽略大小写，
+// 可在配置中追加）的值统一输出为 ***，嵌套在 group 里的同样处理。
+//
+// 【组件】
+//
+// 各模块用 logging.For("OutboxSender") 取带 component 字段的 logger，
+// 可以在包级变量里保存：Init 之前取到的 logger 在 Init 之后同样使用新配置。
+//
+// ============================================================================
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+
+	redacted = "***"
+)
+
+// defaultSensitiveKeys 默认脱敏的字段
+var defaultSensitiveKeys = []string{
+	"password", "secret", "hmac_secret", "token", "access_token", "refresh_token",
+	"authorization", "signature", "x-signature", "cookie",
+}
+
+// root 所有 logger 共用的处理器，Init 时替换其内部实现
+var root = &swapHandler{}
+
+func init() {
+	root.set(newHandler(os.Stdout, &config.LogConfig{}))
+}
+
+// Init 按配置初始化日志，同时接管 slog 默认 logger 和标准库 log 的输出
+func Init(cfg *config.LogConfig) {
+	root.set(newHandler(os.Stdout, cfg))
+	slog.SetDefault(slog.New(root))
+}
+
+// For 取某个组件的 logger
+func For(component string) *slog.Logger {
+	return slog.New(root).With("component", component)
+}
+
+// sensitiveKeys 当前生效的脱敏字段（小写）
+var sensitiveKeys atomic.Pointer[map[string]bool]
+
+// IsSensitive 字段名是否需要脱敏
+func IsSensitive(key string) bool {
+	return (*sensitiveKeys.Load())[strings.ToLower(key)]
+}
+
+// RedactQuery 把 URL 查询参数中敏感字段的值替换为 ***
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return redacted
+	}
+	changed := false
+	for key := range values {
+		if IsSensitive(key) {
+			values[key] = []string{redacted}
+			changed = true
+		}
+	}
+	if !changed {
+		return rawQuery
+	}
+	return values.Encode()
+}
+
+func newHandler(w io.Writer, cfg *config.LogConfig) slog.Handler {
+	sensitive := make(map[string]bool, len(defaultSensitiveKeys)+len(cfg.RedactKeys))
+	for _, key := range defaultSensitiveKeys {
+		sensitive[key] = true
+	}
+	for _, key := range cfg.RedactKeys {
+		sensitive[strings.ToLower(key)] = true
+	}
+	sensitiveKeys.Store(&sensitive)
+
+	opts := &slog.HandlerOptions{
+		Level: parseLevel(cfg.Level),
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if IsSensitive(a.Key) {
+				return slog.String(a.Key, redacted)
+			}
+			return a
+		},
+	}
+
+	var handler slog.Handler
+	if cfg.Format == FormatText {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	return &contextHandler{next: handler}
+}
+
+func parseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// ============================================================================
+// 上下文字段
+// ============================================================================
+
+type fieldsKey struct{}
+
+// WithFields 在 ctx 上追加日志字段（键值对，同 slog 的参数形式），后续用该 ctx 记的日志都会带上
+func WithFields(ctx context.Context, args ...any) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+	existing, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	record := slog.NewRecord(time.Time{}, 0, "", 0)
+	record.Add(args...)
+
+	fields := make([]slog.Attr, 0, len(existing)+record.NumAttrs())
+	fields = append(fields, existing...)
+	record.Attrs(func(a slog.Attr) bool {
+		fields = append(fields, a)
+		return true
+	})
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// contextHandler 把 ctx 中的字段和 trace_id/span_id 加到每条日志上
+type contextHandler struct {
+	next slog.Handler
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		if fields, ok := ctx.Value(fieldsKey{}).([]slog.Attr); ok {
+			record.AddAttrs(fields...)
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			record.AddAttrs(
+				slog.String("trace_id", sc.TraceID().String()),
+				slog.String("span_id", sc.SpanID().String()),
+			)
+		}
+	}
+	return h.next.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{next: h.next.WithGroup(name)}
+}
+
+// swapHandler 可替换实现的处理器：包级变量里保存的 logger 在 Init 后也使用新配置
+// With/WithGroup 派生出的处理器记录派生操作，根处理器被替换后在新实现上重放一次并缓存
+type swapHandler struct {
+	current atomic.Pointer[slog.Handler] // 只有根处理器使用
+
+	parent *swapHandler
+	attrs  []slog.Attr
+	group  string
+	cache  atomic.Pointer[derivedHandler]
+}
+
+type derivedHandler struct {
+	base    *slog.Handler // 派生时根处理器的实现
+	handler slog.Handler
+}
+
+func (h *swapHandler) set(handler slog.Handler) {
+	h.current.Store(&handler)
+}
+
+func (h *swapHandler) root() *swapHandler {
+	for h.parent != nil {
+		h = h.parent
+	}
+	return h
+}
+
+func (h *swapHandler) resolve() slog.Handler {
+	if h.parent == nil {
+		return *h.current.Load()
+	}
+
+	base := h.root().current.Load()
+	if cached := h.cache.Load(); cached != nil && cached.base == base {
+		return cached.handler
+	}
+
+	handler := h.parent.resolve()
+	if len(h.attrs) > 0 {
+		handler = handler.WithAttrs(h.attrs)
+	}
+	if h.group != "" {
+		handler = handler.WithGroup(h.group)
+	}
+	h.cache.Store(&derivedHandler{base: base, handler: handler})
+	return handler
+}
+
+func (h *swapHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.resolve().Enabled(ctx, level)
+}
+
+func (h *swapHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.resolve().Handle(ctx, record)
+}
+
+func (h *swapHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &swapHandler{parent: h, attrs: attrs}
+}
+
+func (h *swapHandler) WithGroup(name string) slog.Handler {
+	return &swapHandler{parent: h, group: name}
+}
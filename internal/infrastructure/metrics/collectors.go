@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"paysystem/internal/infrastructure/logging"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+var metricsLog = logging.For("Metrics")
+
+// RegisterDBStats 注册 MySQL 连接池指标（go_sql_*，db_name 标签为 name）
+func RegisterDBStats(name string, db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// ============================================================================
+// Redis 连接池
+// ============================================================================
+
+var (
+	redisPoolHitsDesc     = prometheus.NewDesc(namespace+"_redis_pool_hits_total", "连接池命中次数", []string{"client"}, nil)
+	redisPoolMissesDesc   = prometheus.NewDesc(namespace+"_redis_pool_misses_total", "连接池未命中次数（新建连接）", []string{"client"}, nil)
+	redisPoolTimeoutsDesc = prometheus.NewDesc(namespace+"_redis_pool_timeouts_total", "等待空闲连接超时次数", []string{"client"}, nil)
+	redisPoolTotalDesc    = prometheus.NewDesc(namespace+"_redis_pool_total_conns", "连接总数", []string{"client"}, nil)
+	redisPoolIdleDesc     = prometheus.NewDesc(namespace+"_redis_pool_idle_conns", "空闲连接数", []string{"client"}, nil)
+	redisPoolStaleDesc    = prometheus.NewDesc(namespace+"_redis_pool_stale_conns_total", "被移除的过期连接数", []string{"client"}, nil)
+)
+
+type redisPoolCollector struct {
+	name   string
+	client *redis.Client
+}
+
+// RegisterRedisPool 注册 Redis 连接池指标，name 区分多个客户端（如 main、redlock-0）
+func RegisterRedisPool(name string, client *redis.Client) {
+	prometheus.MustRegister(&redisPoolCollector{name: name, client: client})
+}
+
+func (c *redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- redisPoolHitsDesc
+	ch <- redisPoolMissesDesc
+	ch <- redisPoolTimeoutsDesc
+	ch <- redisPoolTotalDesc
+	ch <- redisPoolIdleDesc
+	ch <- redisPoolStaleDesc
+}
+
+func (c *redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(redisPoolHitsDesc, prometheus.CounterValue, float64(stats.Hits), c.name)
+	ch <- prometheus.MustNewConstMetric(redisPoolMissesDesc, prometheus.CounterValue, float64(stats.Misses), c.name)
+	ch <- prometheus.MustNewConstMetric(redisPoolTimeoutsDesc, prometheus.CounterValue, float64(stats.Timeouts), c.name)
+	ch <- prometheus.MustNewConstMetric(redisPoolTotalDesc, prometheus.GaugeValue, float64(stats.TotalConns), c.name)
+	ch <- prometheus.MustNewConstMetric(redisPoolIdleDesc, prometheus.GaugeValue, float64(stats.IdleConns), c.name)
+	ch <- prometheus.MustNewConstMetric(redisPoolStaleDesc, prometheus.CounterValue, float64(stats.StaleConns), c.name)
+}
+
+// ============================================================================
+// Outbox 积压
+// ============================================================================
+//
+// 每次抓取时查询一次 outbox_message（status 有索引，只取 COUNT 和 MIN(created_at)），
+// 多实例部署时各实例上报的值相同，看板上取 max 即可。
+
+var (
+	outboxPendingDesc = prometheus.NewDesc(namespace+"_outbox_pending_messages", "待发送的 Outbox 消息数", nil, nil)
+	outboxAgeDesc     = prometheus.NewDesc(namespace+"_outbox_oldest_pending_age_seconds", "最早一条待发送消息已等待的时长", nil, nil)
+)
+
+// OutboxStatsFunc 查询待发送消息数和最早一条的创建时间（没有待发送消息时返回零值时间）
+type OutboxStatsFunc func(ctx context.Context) (int64, time.Time, error)
+
+type outboxCollector struct {
+	stats OutboxStatsFunc
+}
+
+// RegisterOutboxBacklog 注册 Outbox 积压指标
+func RegisterOutboxBacklog(stats OutboxStatsFunc) {
+	prometheus.MustRegister(&outboxCollector{stats: stats})
+}
+
+func (c *outboxCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- outboxPendingDesc
+	ch <- outboxAgeDesc
+}
+
+func (c *outboxCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	count, oldest, err := c.stats(ctx)
+	if err != nil {
+		metricsLog.ErrorContext(ctx, "查询 Outbox 积压失败", "err", err)
+		return
+	}
+
+	age := 0.0
+	if !oldest.IsZero() {
+		age = time.Since(oldest).Seconds()
+	}
+	ch <- prometheus.MustNewConstMetric(outboxPendingDesc, prometheus.GaugeValue, float64(count))
+	ch <- prometheus.MustNewConstMetric(outboxAgeDesc, prometheus.GaugeValue, age)
+}
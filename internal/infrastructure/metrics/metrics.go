@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"paysystem/pkg/errs"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ============================================================================
+// Prometheus 指标
+// ============================================================================
+//
+// 所有指标以 paysystem_ 开头，注册在默认 Registry 上，由 /metrics 暴露：
+//
+//   HTTP       请求数、耗时、处理中请求数（按路由模板，不按实际路径，避免标签爆炸）
+//   业务       支付/退款次数与金额（按商品类型），失败次数（按错误消息键）
+//   分布式锁   加锁等待耗时、加锁失败次数（按锁实现）
+//   Outbox     待发送消息数、最早一条待发送消息的等待时长，已清理（归档/删除）消息数
+//   后台任务   每轮执行耗时
+//   连接池     MySQL、Redis 连接池状态
+//
+// 标签值只使用有限集合（路由模板、错误消息键、商品类型），不要放用户ID、订单号。
+//
+// ============================================================================
+
+const namespace = "paysystem"
+
+// 业务结果
+const (
+	ResultSuccess   = "success"
+	ResultDuplicate = "duplicate" // 幂等命中，返回已有结果
+	ResultFailure   = "failure"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP 请求数",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP 请求耗时",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "route"})
+
+	HTTPInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "正在处理的 HTTP 请求数",
+	})
+
+	PayTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pay_total",
+		Help:      "支付次数",
+	}, []string{"product_type", "result"})
+
+	PayAmount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pay_amount_total",
+		Help:      "支付成功金额",
+	}, []string{"product_type"})
+
+	RefundTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "refund_total",
+		Help:      "退款次数",
+	}, []string{"result"})
+
+	RefundAmount = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "refund_amount_total",
+		Help:      "退款成功金额",
+	})
+
+	BusinessFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "business_failures_total",
+		Help:      "业务失败次数，reason 为错误消息键",
+	}, []string{"operation", "reason"})
+
+	LockWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lock_acquire_wait_seconds",
+		Help:      "分布式锁加锁等待耗时",
+		Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"provider", "result"})
+
+	LockFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lock_acquire_failures_total",
+		Help:      "分布式锁加锁失败次数",
+	}, []string{"provider"})
+
+	OutboxCleaned = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_cleaned_total",
+		Help:      "清理的已发送 Outbox 消息数，topic 为单独配置的主题或 default，mode 为 archive/delete",
+	}, []string{"topic", "mode"})
+
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_run_duration_seconds",
+		Help:      "后台任务每轮执行耗时",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"job"})
+)
+
+// Handler /metrics 处理器
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveJob 记录一轮后台任务的耗时，用法：defer metrics.ObserveJob("outbox_sender", time.Now())
+func ObserveJob(job string, start time.Time) {
+	JobDuration.WithLabelValues(job).Observe(time.Since(start).Seconds())
+}
+
+// ObserveFailure 记录业务失败，reason 取错误消息键（未定义类型的错误为 common.internal）
+func ObserveFailure(operation string, err error) {
+	BusinessFailures.WithLabelValues(operation, errs.From(err).Key).Inc()
+}
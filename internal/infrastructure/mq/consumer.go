@@ -0,0 +1,282 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/infrastructure/tracing"
+
+	"github.com/IBM/sarama"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ============================================================================
+// Kafka 消费者（消费组）
+// ============================================================================
+//
+// 【消息处理流程】
+//
+//   主题 pay_command ──> Handler 处理
+//                           │
+//                           ├── 成功：提交 offset
+//                           │
+//                           ├── 可重试错误：投递到重试主题（retry_count + 1），提交 offset
+//                           │     重试主题的消息在 retry_backoff 之后再交给 Handler
+//                           │
+//                           └── 不可重试错误 / 超过最大重试次数：投递到死信主题，提交 offset
+//
+// 【为什么不直接在原分区上重试？】
+//   一条"毒消息"如果一直处理失败，会阻塞整个分区后面的所有消息。
+//   转投到重试主题后，原分区可以继续往下消费。
+//
+// 【注意】消费是 at-least-once 的，Handler 需要自己保证幂等（见 inbox 表）
+//
+// ============================================================================
+
+var consumerLog = logging.For("KafkaConsumer")
+
+// 重试相关消息头
+const (
+	HeaderMessageID     = "x-message-id"
+	HeaderRetryCount    = "x-retry-count"
+	HeaderOriginalTopic = "x-original-topic"
+	HeaderError         = "x-error"
+)
+
+// ErrNonRetryable 不可重试错误，消息直接进入死信主题
+var ErrNonRetryable = errors.New("消息无法处理")
+
+// NonRetryable 将错误标记为不可重试
+func NonRetryable(err error) error {
+	return fmt.Errorf("%w: %v", ErrNonRetryable, err)
+}
+
+// Message 消费到的消息
+type Message struct {
+	Topic         string
+	Partition     int32
+	Offset        int64
+	Key           string
+	Value         []byte
+	Headers       map[string]string
+	Timestamp     time.Time
+	RetryCount    int
+	OriginalTopic string // 原始主题（从重试主题消费时有意义）
+}
+
+// ID 消息唯一标识，首次消费时为 topic/partition/offset，转投重试主题后保持不变
+func (m *Message) ID() string {
+	if id := m.Headers[HeaderMessageID]; id != "" {
+		return id
+	}
+	return fmt.Sprintf("%s/%d/%d", m.Topic, m.Partition, m.Offset)
+}
+
+// MessageHandler 消息处理函数
+type MessageHandler func(ctx context.Context, msg *Message) error
+
+// Consumer Kafka 消费组消费者
+type Consumer struct {
+	group      sarama.ConsumerGroup
+	cfg        *config.KafkaConsumerConfig
+	handler    MessageHandler
+	topics     []string
+	retryDelay time.Duration
+	stopCh     chan struct{}
+	done       chan struct{}
+}
+
+// NewConsumer 创建消费者，订阅业务主题及其重试主题
+func NewConsumer(brokers []string, cfg *config.KafkaConsumerConfig, handler MessageHandler) (*Consumer, error) {
+	kafkaConfig := sarama.NewConfig()
+	kafkaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+	kafkaConfig.Consumer.Return.Errors = true
+
+	group, err := sarama.NewConsumerGroup(brokers, cfg.GroupID, kafkaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	topics := []string{cfg.Topic}
+	if cfg.RetryTopic != "" {
+		topics = append(topics, cfg.RetryTopic)
+	}
+
+	return &Consumer{
+		group:      group,
+		cfg:        cfg,
+		handler:    handler,
+		topics:     topics,
+		retryDelay: time.Duration(cfg.RetryBackoffSeconds) * time.Second,
+		stopCh:     make(chan struct{}),
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// Start 启动消费，阻塞直到 ctx 取消、Stop 或消费者关闭
+func (c *Consumer) Start(ctx context.Context) {
+	defer close(c.done)
+	consumerLog.InfoContext(ctx, "消费者启动", "group", c.cfg.GroupID, "topics", c.topics)
+
+	// Stop 时取消消费：ConsumeClaim 处理完当前消息后返回，会话提交已处理的 offset 后 Consume 才返回
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	go func() {
+		for err := range c.group.Errors() {
+			consumerLog.ErrorContext(ctx, "消费组错误", "err", err)
+		}
+	}()
+
+	for {
+		// 发生 rebalance 时 Consume 会返回，需要循环重新加入
+		if err := c.group.Consume(ctx, c.topics, c); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return
+			}
+			consumerLog.ErrorContext(ctx, "消费失败", "err", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+		if ctx.Err() != nil {
+			consumerLog.InfoContext(ctx, "收到停止信号，消费者退出")
+			return
+		}
+	}
+}
+
+// Close 关闭消费者
+func (c *Consumer) Close() error {
+	return c.group.Close()
+}
+
+// Stop 停止消费，等待正在处理的消息处理完、Start 返回后再关闭消费组
+// 先关闭消费组的话 Close 不会等待 ConsumeClaim，处理中的消息会被打断
+func (c *Consumer) Stop(ctx context.Context) error {
+	close(c.stopCh)
+	select {
+	case <-c.done:
+		return c.Close()
+	case <-ctx.Done():
+		return errors.Join(fmt.Errorf("等待消费者退出超时: %w", ctx.Err()), c.Close())
+	}
+}
+
+// Setup 实现 sarama.ConsumerGroupHandler
+func (c *Consumer) Setup(sarama.ConsumerGroupSession) error { return nil }
+
+// Cleanup 实现 sarama.ConsumerGroupHandler
+func (c *Consumer) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+// ConsumeClaim 实现 sarama.ConsumerGroupHandler
+func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	ctx := session.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case raw, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			msg := newMessage(raw)
+
+			// 重试主题的消息需要等到退避时间之后再处理
+			if msg.RetryCount > 0 && c.retryDelay > 0 {
+				wait := time.Until(msg.Timestamp.Add(c.retryDelay))
+				if wait > 0 {
+					select {
+					case <-ctx.Done():
+						return nil
+					case <-time.After(wait):
+					}
+				}
+			}
+
+			// 已经开始处理的消息不随停止消费取消，处理完（或转投重试/死信主题）再退出
+			if err := c.process(context.WithoutCancel(ctx), msg); err != nil {
+				// 转投重试/死信主题失败，不提交 offset，等待重新投递
+				consumerLog.ErrorContext(ctx, "消息转投失败", "topic", msg.Topic, "offset", msg.Offset, "err", err)
+				return err
+			}
+			session.MarkMessage(raw, "")
+		}
+	}
+}
+
+func (c *Consumer) process(ctx context.Context, msg *Message) error {
+	// 继续生产者写入消息头的 trace，转投重试/死信主题也在同一条 trace 下
+	ctx, span := tracing.Tracer().Start(tracing.Extract(ctx, msg.Headers), "kafka.process "+msg.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingOperationDeliver,
+			semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+		),
+	)
+	defer span.End()
+
+	err := c.handler(ctx, msg)
+	if err == nil {
+		return nil
+	}
+	tracing.RecordError(span, err)
+
+	consumerLog.ErrorContext(ctx, "消息处理失败", "topic", msg.Topic, "key", msg.Key, "retry", msg.RetryCount, "err", err)
+
+	headers := make(map[string]string, len(msg.Headers)+4)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderMessageID] = msg.ID()
+	headers[HeaderOriginalTopic] = msg.OriginalTopic
+	headers[HeaderError] = err.Error()
+
+	if errors.Is(err, ErrNonRetryable) || msg.RetryCount >= c.cfg.MaxRetries || c.cfg.RetryTopic == "" {
+		headers[HeaderRetryCount] = strconv.Itoa(msg.RetryCount)
+		consumerLog.WarnContext(ctx, "消息进入死信主题", "topic", c.cfg.DeadLetterTopic, "key", msg.Key)
+		return SendMessage(ctx, c.cfg.DeadLetterTopic, msg.Key, string(msg.Value), headers)
+	}
+
+	headers[HeaderRetryCount] = strconv.Itoa(msg.RetryCount + 1)
+	return SendMessage(ctx, c.cfg.RetryTopic, msg.Key, string(msg.Value), headers)
+}
+
+func newMessage(raw *sarama.ConsumerMessage) *Message {
+	msg := &Message{
+		Topic:         raw.Topic,
+		Partition:     raw.Partition,
+		Offset:        raw.Offset,
+		Key:           string(raw.Key),
+		Value:         raw.Value,
+		Headers:       make(map[string]string, len(raw.Headers)),
+		Timestamp:     raw.Timestamp,
+		OriginalTopic: raw.Topic,
+	}
+	for _, h := range raw.Headers {
+		msg.Headers[string(h.Key)] = string(h.Value)
+	}
+	if v, ok := msg.Headers[HeaderRetryCount]; ok {
+		msg.RetryCount, _ = strconv.Atoi(v)
+	}
+	if v, ok := msg.Headers[HeaderOriginalTopic]; ok && v != "" {
+		msg.OriginalTopic = v
+	}
+	return msg
+}
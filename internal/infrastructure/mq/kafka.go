@@ -1,14 +1,25 @@
 package mq
 
 import (
+	"context"
+	"errors"
 	"log"
 
 	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/infrastructure/tracing"
 
 	"github.com/IBM/sarama"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var KafkaProducer sarama.SyncProducer
+var kafkaLog = logging.For("Kafka")
+
+var (
+	KafkaClient   sarama.Client
+	KafkaProducer sarama.SyncProducer
+)
 
 // InitKafka 初始化 Kafka 生产者
 func InitKafka(cfg *config.KafkaConfig) sarama.SyncProducer {
@@ -17,31 +28,85 @@ func InitKafka(cfg *config.KafkaConfig) sarama.SyncProducer {
 	kafkaConfig.Producer.Retry.Max = 3                    // 重试次数
 	kafkaConfig.Producer.Return.Successes = true          // 返回成功消息
 
-	producer, err := sarama.NewSyncProducer(cfg.Brokers, kafkaConfig)
+	// 单独持有 client，健康检查用它拉取集群元数据
+	client, err := sarama.NewClient(cfg.Brokers, kafkaConfig)
+	if err != nil {
+		log.Fatalf("连接 Kafka 失败: %v", err)
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
 	if err != nil {
 		log.Fatalf("创建 Kafka 生产者失败: %v", err)
 	}
 
+	KafkaClient = client
 	KafkaProducer = producer
-	log.Println("Kafka 生产者创建成功")
+	kafkaLog.Info("Kafka 生产者创建成功")
 	return producer
 }
 
 // SendMessage 发送消息到 Kafka
-func SendMessage(topic, key, value string) error {
+// 发送过程记为 ctx 下的生产者 span，并把该 span 写入消息头，消费者据此继续同一条 trace
+func SendMessage(ctx context.Context, topic, key, value string, headers map[string]string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "kafka.send "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingOperationPublish,
+		),
+	)
+	defer span.End()
+
+	traceHeaders := make(map[string]string, len(headers)+3)
+	for k, v := range headers {
+		traceHeaders[k] = v
+	}
+	tracing.Inject(ctx, traceHeaders)
+
 	msg := &sarama.ProducerMessage{
 		Topic: topic,
 		Key:   sarama.StringEncoder(key),
 		Value: sarama.StringEncoder(value),
 	}
+	for k, v := range traceHeaders {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
+	}
 
-	_, _, err := KafkaProducer.SendMessage(msg)
-	return err
+	partition, offset, err := KafkaProducer.SendMessage(msg)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	span.SetAttributes(
+		semconv.MessagingKafkaDestinationPartition(int(partition)),
+		semconv.MessagingKafkaMessageOffset(int(offset)),
+	)
+	return nil
+}
+
+// Ping 检查 Kafka 是否可用：刷新集群元数据，并确认有可连接的 broker
+func Ping() error {
+	if KafkaClient == nil || KafkaClient.Closed() {
+		return errors.New("Kafka 生产者未初始化或已关闭")
+	}
+	if err := KafkaClient.RefreshMetadata(); err != nil {
+		return err
+	}
+	if len(KafkaClient.Brokers()) == 0 {
+		return errors.New("没有可用的 Kafka broker")
+	}
+	return nil
 }
 
-// CloseKafka 关闭 Kafka 生产者
-func CloseKafka() {
+// CloseKafka 关闭 Kafka 生产者（从 client 创建的生产者不会关闭 client，需要单独关闭）
+// SyncProducer 关闭时会等待已提交的消息发送完成，调用前应先停止所有发送方
+func CloseKafka() error {
+	var errs []error
 	if KafkaProducer != nil {
-		KafkaProducer.Close()
+		errs = append(errs, KafkaProducer.Close())
+	}
+	if KafkaClient != nil && !KafkaClient.Closed() {
+		errs = append(errs, KafkaClient.Close())
 	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+
+	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/logging"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ============================================================================
+// 链路追踪（OpenTelemetry）
+// ============================================================================
+//
+// 一次支付经过的每一段都有 span：
+//
+//   HTTP 请求（gin 中间件）
+//     ├── MySQL 语句（GORM 插件）
+//     ├── Redis 命令（go-redis Hook，包括分布式锁的 Lua 脚本）
+//     └── 写入 outbox_message：当前 trace 上下文（traceparent）写进消息头
+//   OutboxSender 发送（异步，另一个 goroutine）
+//     └── Kafka 生产者 span，父 span 取自消息头，下游消费者继续同一条 trace
+//
+// 传播格式为 W3C Trace Context + Baggage。
+//
+// 【导出器】
+//
+//   otlp    OTLP/HTTP 发往 Collector（如 Jaeger、Tempo 前面的 otel-collector）
+//   stdout  打印到标准输出，本地开发使用
+//
+// 未开启时仍然设置传播器，上游带来的 traceparent 会原样透传到 Kafka 消息头。
+//
+// ============================================================================
+
+var tracingLog = logging.For("Tracing")
+
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+
+	instrumentationName = "paysystem"
+)
+
+// Init 初始化全局 TracerProvider，返回的函数在退出时调用，刷出未导出的 span
+func Init(cfg *config.TracingConfig) func(context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }
+	}
+
+	exporter, err := newExporter(cfg)
+	if err != nil {
+		log.Fatalf("创建链路追踪导出器失败: %v", err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "paysystem"
+	}
+	hostname, _ := os.Hostname()
+
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.HostName(hostname),
+	)
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// 上游已经决定采样时跟随上游，否则按比例采样
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	tracingLog.Info("链路追踪已开启", "exporter", cfg.Exporter, "sample_ratio", cfg.SampleRatio)
+	return provider.Shutdown
+}
+
+func newExporter(cfg *config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP, "":
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	default:
+		log.Fatalf("未知的链路追踪导出器: %s", cfg.Exporter)
+		return nil, nil
+	}
+}
+
+// Tracer 本服务使用的 Tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Inject 把 ctx 中的 trace 上下文写入消息头（traceparent、tracestate、baggage）
+func Inject(ctx context.Context, headers map[string]string) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+}
+
+// Extract 从消息头恢复 trace 上下文
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}
+
+// ExtractHTTP 从 HTTP 请求头恢复 trace 上下文
+func ExtractHTTP(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// RecordError 记录错误并把 span 标记为失败
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
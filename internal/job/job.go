@@ -0,0 +1,17 @@
+package job
+
+import (
+	"context"
+	"fmt"
+)
+
+// stopAndWait 通知任务退出，并等待正在处理的批次完成、Start 返回
+func stopAndWait(ctx context.Context, stopCh chan struct{}, done <-chan struct{}) error {
+	close(stopCh)
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待任务退出超时: %w", ctx.Err())
+	}
+}
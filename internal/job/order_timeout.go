@@ -2,64 +2,141 @@ package job
 
 import (
 	"context"
-	"log"
 	"time"
 
 	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/cache"
+	"paysystem/internal/infrastructure/election"
+	"paysystem/internal/infrastructure/health"
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/infrastructure/metrics"
 	"paysystem/internal/model"
 	"paysystem/internal/repository"
+	"paysystem/internal/service"
 
+	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 )
 
+var (
+	timeoutLog    = logging.For("OrderTimeoutJob")
+	compensateLog = logging.For("PayingOrderCompensateJob")
+)
+
+// OrderTimeoutJob 订单超时关闭任务
+//
+// 订单创建时按过期时间加入 Redis 延迟队列，任务每隔 pollInterval 拉取已到期的订单并关闭，
+// 订单能在过期后 1 秒内关闭，且不需要反复扫描 pay_order 表。
+// 原来的全表扫描保留为兜底（scanInterval 间隔较长），处理入队失败或出队后进程崩溃的订单。
+// 多实例部署时只有选举出的主节点执行，其他实例空转等待接管。
 type OrderTimeoutJob struct {
-	db        *gorm.DB
-	orderRepo *repository.OrderRepository
-	cfg       *config.Config
-	stopCh    chan struct{}
-	interval  time.Duration
-	batchSize int
+	db           *gorm.DB
+	orderRepo    *repository.OrderRepository
+	orderService *service.OrderService
+	expireQueue  *cache.DelayQueue
+	leader       *election.Elector
+	cfg          *config.Config
+	stopCh       chan struct{}
+	done         chan struct{}
+	pollInterval time.Duration
+	scanInterval time.Duration
+	batchSize    int
 }
 
-func NewOrderTimeoutJob(db *gorm.DB, cfg *config.Config) *OrderTimeoutJob {
+func NewOrderTimeoutJob(db *gorm.DB, redisClient *redis.Client, leader *election.Elector, cfg *config.Config) *OrderTimeoutJob {
 	return &OrderTimeoutJob{
-		db:        db,
-		orderRepo: repository.NewOrderRepository(db),
-		cfg:       cfg,
-		stopCh:    make(chan struct{}),
-		interval:  10 * time.Second,
-		batchSize: 100,
+		db:           db,
+		orderRepo:    repository.NewOrderRepository(db),
+		orderService: service.NewOrderService(db, redisClient, cfg),
+		expireQueue:  cache.NewOrderExpireQueue(redisClient),
+		leader:       leader,
+		cfg:          cfg,
+		stopCh:       make(chan struct{}),
+		done:         make(chan struct{}),
+		pollInterval: 500 * time.Millisecond,
+		scanInterval: 5 * time.Minute,
+		batchSize:    100,
 	}
 }
 
 func (j *OrderTimeoutJob) Start(ctx context.Context) {
-	log.Println("[OrderTimeoutJob] 订单超时任务启动")
+	defer close(j.done)
+	timeoutLog.InfoContext(ctx, "订单超时任务启动")
+	health.RegisterJob("order_timeout", j.pollInterval)
 
-	ticker := time.NewTicker(j.interval)
-	defer ticker.Stop()
+	pollTicker := time.NewTicker(j.pollInterval)
+	defer pollTicker.Stop()
+	scanTicker := time.NewTicker(j.scanInterval)
+	defer scanTicker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("[OrderTimeoutJob] 收到停止信号，任务退出")
+			timeoutLog.InfoContext(ctx, "收到停止信号，任务退出")
 			return
 		case <-j.stopCh:
-			log.Println("[OrderTimeoutJob] 任务停止")
+			timeoutLog.InfoContext(ctx, "任务停止")
 			return
-		case <-ticker.C:
-			j.closeExpiredOrders(ctx)
+		case <-pollTicker.C:
+			health.Beat("order_timeout")
+			if j.leader.IsLeader() {
+				j.closeDueOrders(ctx)
+			}
+		case <-scanTicker.C:
+			health.Beat("order_timeout")
+			if j.leader.IsLeader() {
+				j.closeExpiredOrders(ctx)
+			}
 		}
 	}
 }
 
-func (j *OrderTimeoutJob) Stop() {
-	close(j.stopCh)
+// Stop 通知任务退出，等待当前批次处理完
+func (j *OrderTimeoutJob) Stop(ctx context.Context) error {
+	return stopAndWait(ctx, j.stopCh, j.done)
+}
+
+// closeDueOrders 从延迟队列拉取到期订单并关闭
+func (j *OrderTimeoutJob) closeDueOrders(ctx context.Context) {
+	defer metrics.ObserveJob("order_timeout_poll", time.Now())
+
+	orderNos, err := j.expireQueue.PopDue(ctx, j.batchSize)
+	if err != nil {
+		timeoutLog.ErrorContext(ctx, "拉取超时队列失败", "err", err)
+		return
+	}
+
+	for _, orderNo := range orderNos {
+		order, err := j.orderRepo.GetByOrderNo(ctx, orderNo)
+		if err != nil {
+			timeoutLog.ErrorContext(ctx, "查询订单失败", "order_no", orderNo, "err", err)
+			continue
+		}
+
+		// 已支付、已取消的订单直接忽略
+		if order.Status != model.OrderStatusCreated {
+			continue
+		}
+
+		// 各实例时钟不一致时可能提前出队，放回队列等下次处理
+		if time.Now().Before(order.ExpiredAt) {
+			if err := j.expireQueue.Add(ctx, orderNo, order.ExpiredAt); err != nil {
+				timeoutLog.ErrorContext(ctx, "订单重新入队失败", "order_no", orderNo, "err", err)
+			}
+			continue
+		}
+
+		j.closeOrder(ctx, order)
+	}
 }
 
+// closeExpiredOrders 兜底扫描：关闭延迟队列漏掉的超时订单
 func (j *OrderTimeoutJob) closeExpiredOrders(ctx context.Context) {
+	defer metrics.ObserveJob("order_timeout_scan", time.Now())
+
 	orders, err := j.orderRepo.GetExpiredOrders(ctx, j.batchSize)
 	if err != nil {
-		log.Printf("[OrderTimeoutJob] 查询超时订单失败: %v", err)
+		timeoutLog.ErrorContext(ctx, "查询超时订单失败", "err", err)
 		return
 	}
 
@@ -67,47 +144,60 @@ func (j *OrderTimeoutJob) closeExpiredOrders(ctx context.Context) {
 		return
 	}
 
-	log.Printf("[OrderTimeoutJob] 发现 %d 个超时订单", len(orders))
+	timeoutLog.InfoContext(ctx, "兜底扫描发现超时订单", "count", len(orders))
 
 	closedCount := 0
 	for _, order := range orders {
-		err := j.orderRepo.UpdateStatus(ctx, nil, order.OrderNo, model.OrderStatusCreated, model.OrderStatusClosed)
-		if err != nil {
-			log.Printf("[OrderTimeoutJob] 关闭订单失败: orderNo=%s, err=%v", order.OrderNo, err)
-			continue
+		if j.closeOrder(ctx, order) {
+			closedCount++
 		}
-		closedCount++
-		log.Printf("[OrderTimeoutJob] 订单已超时关闭: orderNo=%s, userID=%d, amount=%d",
-			order.OrderNo, order.UserID, order.Amount)
 	}
 
-	log.Printf("[OrderTimeoutJob] 本次关闭 %d 个超时订单", closedCount)
+	timeoutLog.InfoContext(ctx, "兜底扫描关闭超时订单", "closed", closedCount)
 }
 
+func (j *OrderTimeoutJob) closeOrder(ctx context.Context, order *model.PayOrder) bool {
+	if err := j.orderService.CloseExpiredOrder(ctx, order); err != nil {
+		timeoutLog.ErrorContext(ctx, "关闭订单失败", "order_no", order.OrderNo, "err", err)
+		return false
+	}
+	timeoutLog.InfoContext(ctx, "订单已超时关闭", "order_no", order.OrderNo, "user_id", order.UserID, "amount", order.Amount)
+	return true
+}
+
+// PayingOrderCompensateJob 支付中订单补偿任务，多实例部署时只有主节点执行
 type PayingOrderCompensateJob struct {
 	db              *gorm.DB
 	orderRepo       *repository.OrderRepository
+	orderService    *service.OrderService
 	transactionRepo *repository.TransactionRepository
+	leader          *election.Elector
 	cfg             *config.Config
 	stopCh          chan struct{}
+	done            chan struct{}
 	interval        time.Duration
 	batchSize       int
 }
 
-func NewPayingOrderCompensateJob(db *gorm.DB, cfg *config.Config) *PayingOrderCompensateJob {
+func NewPayingOrderCompensateJob(db *gorm.DB, redisClient *redis.Client, leader *election.Elector, cfg *config.Config) *PayingOrderCompensateJob {
 	return &PayingOrderCompensateJob{
 		db:              db,
 		orderRepo:       repository.NewOrderRepository(db),
+		orderService:    service.NewOrderService(db, redisClient, cfg),
 		transactionRepo: repository.NewTransactionRepository(db),
+		leader:          leader,
 		cfg:             cfg,
 		stopCh:          make(chan struct{}),
+		done:            make(chan struct{}),
 		interval:        30 * time.Second,
 		batchSize:       50,
 	}
 }
 
 func (j *PayingOrderCompensateJob) Start(ctx context.Context) {
-	log.Println("[PayingOrderCompensateJob] 补偿任务启动")
+	defer close(j.done)
+	compensateLog.InfoContext(ctx, "补偿任务启动")
+	health.RegisterJob("paying_order_compensate", j.interval)
 
 	ticker := time.NewTicker(j.interval)
 	defer ticker.Stop()
@@ -115,26 +205,32 @@ func (j *PayingOrderCompensateJob) Start(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("[PayingOrderCompensateJob] 收到停止信号，任务退出")
+			compensateLog.InfoContext(ctx, "收到停止信号，任务退出")
 			return
 		case <-j.stopCh:
-			log.Println("[PayingOrderCompensateJob] 任务停止")
+			compensateLog.InfoContext(ctx, "任务停止")
 			return
 		case <-ticker.C:
-			j.compensatePayingOrders(ctx)
+			health.Beat("paying_order_compensate")
+			if j.leader.IsLeader() {
+				j.compensatePayingOrders(ctx)
+			}
 		}
 	}
 }
 
-func (j *PayingOrderCompensateJob) Stop() {
-	close(j.stopCh)
+// Stop 通知任务退出，等待当前批次处理完
+func (j *PayingOrderCompensateJob) Stop(ctx context.Context) error {
+	return stopAndWait(ctx, j.stopCh, j.done)
 }
 
 func (j *PayingOrderCompensateJob) compensatePayingOrders(ctx context.Context) {
+	defer metrics.ObserveJob("paying_order_compensate", time.Now())
+
 	beforeTime := time.Now().Add(-5 * time.Minute)
 	orders, err := j.orderRepo.GetPayingOrders(ctx, beforeTime, j.batchSize)
 	if err != nil {
-		log.Printf("[PayingOrderCompensateJob] 查询订单失败: %v", err)
+		compensateLog.ErrorContext(ctx, "查询订单失败", "err", err)
 		return
 	}
 
@@ -142,7 +238,7 @@ func (j *PayingOrderCompensateJob) compensatePayingOrders(ctx context.Context) {
 		return
 	}
 
-	log.Printf("[PayingOrderCompensateJob] 发现 %d 个需要补偿的订单", len(orders))
+	compensateLog.InfoContext(ctx, "发现需要补偿的订单", "count", len(orders))
 
 	for _, order := range orders {
 		j.compensateOrder(ctx, order)
@@ -152,31 +248,31 @@ func (j *PayingOrderCompensateJob) compensatePayingOrders(ctx context.Context) {
 func (j *PayingOrderCompensateJob) compensateOrder(ctx context.Context, order *model.PayOrder) {
 	trans, err := j.transactionRepo.GetByOrderNo(ctx, order.OrderNo)
 	if err != nil {
-		log.Printf("[PayingOrderCompensateJob] 查询流水失败: orderNo=%s, err=%v", order.OrderNo, err)
+		compensateLog.ErrorContext(ctx, "查询流水失败", "order_no", order.OrderNo, "err", err)
 		return
 	}
 
 	if trans != nil && trans.Type == model.TransactionTypePay {
-		log.Printf("[PayingOrderCompensateJob] 发现已扣款但状态未更新的订单: orderNo=%s", order.OrderNo)
+		compensateLog.InfoContext(ctx, "发现已扣款但状态未更新的订单", "order_no", order.OrderNo)
 
-		err := j.orderRepo.UpdateStatus(ctx, nil, order.OrderNo, model.OrderStatusPaying, model.OrderStatusPaid)
+		err := j.orderService.CompletePayingOrder(ctx, order)
 		if err != nil {
-			log.Printf("[PayingOrderCompensateJob] 补偿更新订单状态失败: orderNo=%s, err=%v", order.OrderNo, err)
+			compensateLog.ErrorContext(ctx, "补偿更新订单状态失败", "order_no", order.OrderNo, "err", err)
 		} else {
-			log.Printf("[PayingOrderCompensateJob] 补偿成功，订单状态已更新为 PAID: orderNo=%s", order.OrderNo)
+			compensateLog.InfoContext(ctx, "补偿成功，订单状态已更新为 PAID", "order_no", order.OrderNo)
 		}
 		return
 	}
 
 	orderTimeout := time.Duration(j.cfg.Business.OrderTimeoutMinutes) * time.Minute
 	if time.Since(order.CreatedAt) > orderTimeout {
-		log.Printf("[PayingOrderCompensateJob] 订单超时且无扣款流水，准备关闭: orderNo=%s", order.OrderNo)
+		compensateLog.InfoContext(ctx, "订单超时且无扣款流水，准备关闭", "order_no", order.OrderNo)
 
-		err := j.orderRepo.UpdateStatus(ctx, nil, order.OrderNo, model.OrderStatusPaying, model.OrderStatusFailed)
+		err := j.orderService.FailPayingOrder(ctx, order, "支付超时且无扣款流水")
 		if err != nil {
-			log.Printf("[PayingOrderCompensateJob] 关闭订单失败: orderNo=%s, err=%v", order.OrderNo, err)
+			compensateLog.ErrorContext(ctx, "关闭订单失败", "order_no", order.OrderNo, "err", err)
 		} else {
-			log.Printf("[PayingOrderCompensateJob] 订单已标记为失败: orderNo=%s", order.OrderNo)
+			compensateLog.InfoContext(ctx, "订单已标记为失败", "order_no", order.OrderNo)
 		}
 	}
 }
@@ -0,0 +1,208 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/health"
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/infrastructure/metrics"
+	"paysystem/internal/model"
+	"paysystem/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var retentionLog = logging.For("OutboxRetentionJob")
+
+// OutboxRetentionJob 已发送消息清理任务
+//
+// outbox_message 只增不删的话，表和 status 索引会越来越大，拖慢 GetPendingMessages。
+// 该任务定期把超过保留时长的 SENT 消息小批量移入归档表（或直接删除），
+// 每批一个短事务，避免长时间锁表。
+type OutboxRetentionJob struct {
+	db         *gorm.DB
+	outboxRepo *repository.OutboxRepository
+	cfg        *config.OutboxRetentionConfig
+	stopCh     chan struct{}
+	done       chan struct{}
+	interval   time.Duration
+	batchSize  int
+	maxBatches int
+}
+
+// retentionRule 一条清理规则：topics 为空表示默认规则（排除所有单独配置的主题）
+type retentionRule struct {
+	topics        []string
+	excludeTopics []string
+	mode          string
+	retention     time.Duration
+}
+
+func NewOutboxRetentionJob(db *gorm.DB, cfg *config.Config) *OutboxRetentionJob {
+	retentionCfg := &cfg.Outbox.Retention
+
+	interval := time.Duration(retentionCfg.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	batchSize := retentionCfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	maxBatches := retentionCfg.MaxBatches
+	if maxBatches <= 0 {
+		maxBatches = 20
+	}
+
+	return &OutboxRetentionJob{
+		db:         db,
+		outboxRepo: repository.NewOutboxRepository(db),
+		cfg:        retentionCfg,
+		stopCh:     make(chan struct{}),
+		done:       make(chan struct{}),
+		interval:   interval,
+		batchSize:  batchSize,
+		maxBatches: maxBatches,
+	}
+}
+
+func (j *OutboxRetentionJob) Start(ctx context.Context) {
+	defer close(j.done)
+	retentionLog.InfoContext(ctx, "消息清理任务启动")
+	health.RegisterJob("outbox_retention", j.interval)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			retentionLog.InfoContext(ctx, "收到停止信号，任务退出")
+			return
+		case <-j.stopCh:
+			retentionLog.InfoContext(ctx, "任务停止")
+			return
+		case <-ticker.C:
+			health.Beat("outbox_retention")
+			j.cleanSentMessages(ctx)
+		}
+	}
+}
+
+// Stop 通知任务退出，等待当前批次处理完
+func (j *OutboxRetentionJob) Stop(ctx context.Context) error {
+	return stopAndWait(ctx, j.stopCh, j.done)
+}
+
+// label 指标中的主题标签：单独配置的主题用主题名，默认规则为 default
+func (r retentionRule) label() string {
+	if len(r.topics) == 1 {
+		return r.topics[0]
+	}
+	return "default"
+}
+
+func (j *OutboxRetentionJob) rules() []retentionRule {
+	rules := make([]retentionRule, 0, len(j.cfg.Topics)+1)
+	overridden := make([]string, 0, len(j.cfg.Topics))
+
+	for topic, topicCfg := range j.cfg.Topics {
+		overridden = append(overridden, topic)
+
+		mode := topicCfg.Mode
+		if mode == "" {
+			mode = j.cfg.Mode
+		}
+		hours := topicCfg.RetentionHours
+		if hours <= 0 {
+			hours = j.cfg.RetentionHours
+		}
+		rules = append(rules, retentionRule{
+			topics:    []string{topic},
+			mode:      mode,
+			retention: time.Duration(hours) * time.Hour,
+		})
+	}
+
+	rules = append(rules, retentionRule{
+		excludeTopics: overridden,
+		mode:          j.cfg.Mode,
+		retention:     time.Duration(j.cfg.RetentionHours) * time.Hour,
+	})
+	return rules
+}
+
+func (j *OutboxRetentionJob) cleanSentMessages(ctx context.Context) {
+	defer metrics.ObserveJob("outbox_retention", time.Now())
+
+	for _, rule := range j.rules() {
+		if rule.mode != config.OutboxRetentionArchive && rule.mode != config.OutboxRetentionDelete {
+			continue
+		}
+		if rule.retention <= 0 {
+			continue
+		}
+
+		count, err := j.cleanByRule(ctx, rule)
+		if err != nil {
+			retentionLog.ErrorContext(ctx, "清理消息失败", "topics", rule.topics, "mode", rule.mode, "err", err)
+		}
+		if count > 0 {
+			retentionLog.InfoContext(ctx, "清理已发送消息", "count", count, "topics", rule.topics, "mode", rule.mode)
+		}
+	}
+}
+
+func (j *OutboxRetentionJob) cleanByRule(ctx context.Context, rule retentionRule) (int64, error) {
+	before := time.Now().Add(-rule.retention)
+
+	cleaned := metrics.OutboxCleaned.WithLabelValues(rule.label(), rule.mode)
+
+	var total int64
+	for i := 0; i < j.maxBatches; i++ {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		// 收到停止通知时做完当前批次就返回，剩下的下次再清理
+		select {
+		case <-j.stopCh:
+			return total, nil
+		default:
+		}
+
+		messages, err := j.outboxRepo.GetSentBefore(ctx, rule.topics, rule.excludeTopics, before, j.batchSize)
+		if err != nil {
+			return total, err
+		}
+		if len(messages) == 0 {
+			return total, nil
+		}
+
+		var affected int64
+		if rule.mode == config.OutboxRetentionArchive {
+			affected, err = j.outboxRepo.Archive(ctx, messages)
+		} else {
+			affected, err = j.outboxRepo.DeleteSent(ctx, messageIDs(messages))
+		}
+		cleaned.Add(float64(affected))
+		total += affected
+		if err != nil {
+			return total, err
+		}
+
+		if len(messages) < j.batchSize {
+			return total, nil
+		}
+	}
+	return total, nil
+}
+
+func messageIDs(messages []*model.OutboxMessage) []int64 {
+	ids := make([]int64, 0, len(messages))
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
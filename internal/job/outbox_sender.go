@@ -2,22 +2,30 @@ package job
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/health"
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/infrastructure/metrics"
 	"paysystem/internal/infrastructure/mq"
+	"paysystem/internal/infrastructure/tracing"
 	"paysystem/internal/model"
 	"paysystem/internal/repository"
 
 	"gorm.io/gorm"
 )
 
+var outboxLog = logging.For("OutboxSender")
+
 type OutboxSender struct {
 	db         *gorm.DB
 	outboxRepo *repository.OutboxRepository
 	cfg        *config.Config
 	stopCh     chan struct{}
+	done       chan struct{}
 	interval   time.Duration
 	batchSize  int
 }
@@ -28,13 +36,16 @@ func NewOutboxSender(db *gorm.DB, cfg *config.Config) *OutboxSender {
 		outboxRepo: repository.NewOutboxRepository(db),
 		cfg:        cfg,
 		stopCh:     make(chan struct{}),
+		done:       make(chan struct{}),
 		interval:   100 * time.Millisecond,
 		batchSize:  100,
 	}
 }
 
 func (s *OutboxSender) Start(ctx context.Context) {
-	log.Println("[OutboxSender] 消息发送任务启动")
+	defer close(s.done)
+	outboxLog.InfoContext(ctx, "消息发送任务启动")
+	health.RegisterJob("outbox_sender", s.interval)
 
 	ticker := time.NewTicker(s.interval)
 	defer ticker.Stop()
@@ -42,60 +53,77 @@ func (s *OutboxSender) Start(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("[OutboxSender] 收到停止信号，任务退出")
+			outboxLog.InfoContext(ctx, "收到停止信号，任务退出")
 			return
 		case <-s.stopCh:
-			log.Println("[OutboxSender] 任务停止")
+			outboxLog.InfoContext(ctx, "任务停止")
 			return
 		case <-ticker.C:
+			health.Beat("outbox_sender")
 			s.processPendingMessages(ctx)
 		}
 	}
 }
 
-func (s *OutboxSender) Stop() {
-	close(s.stopCh)
+// Stop 通知任务退出，等待当前批次发送完，再补发一轮
+// 关闭前最后写入 outbox 的消息（如 HTTP 关闭前完成的支付）尽量在本次退出前发出去
+// 需要在关闭 Kafka 生产者之前调用
+func (s *OutboxSender) Stop(ctx context.Context) error {
+	if err := stopAndWait(ctx, s.stopCh, s.done); err != nil {
+		return err
+	}
+	if err := s.processPendingMessages(ctx); err != nil {
+		return fmt.Errorf("补发消息失败: %w", err)
+	}
+	return nil
 }
 
-func (s *OutboxSender) processPendingMessages(ctx context.Context) {
+func (s *OutboxSender) processPendingMessages(ctx context.Context) error {
+	defer metrics.ObserveJob("outbox_sender", time.Now())
+
 	messages, err := s.outboxRepo.GetPendingMessages(ctx, s.batchSize)
 	if err != nil {
-		log.Printf("[OutboxSender] 查询消息失败: %v", err)
-		return
-	}
-
-	if len(messages) == 0 {
-		return
+		outboxLog.ErrorContext(ctx, "查询消息失败", "err", err)
+		return err
 	}
 
 	for _, msg := range messages {
 		s.sendMessage(ctx, msg)
 	}
+	return nil
 }
 
 func (s *OutboxSender) sendMessage(ctx context.Context, msg *model.OutboxMessage) {
-	err := mq.SendMessage(msg.Topic, msg.MessageKey, msg.Payload)
+	var headers map[string]string
+	if msg.Headers != "" {
+		if err := json.Unmarshal([]byte(msg.Headers), &headers); err != nil {
+			outboxLog.WarnContext(ctx, "解析消息头失败，按无消息头发送", "id", msg.ID, "err", err)
+		}
+	}
+
+	// 消息头里带着写入 outbox 时的 trace 上下文，发送 span 挂在发起请求的 trace 下
+	err := mq.SendMessage(tracing.Extract(ctx, headers), msg.Topic, msg.MessageKey, msg.Payload, headers)
 
 	if err == nil {
 		if updateErr := s.outboxRepo.UpdateStatus(ctx, msg.ID, model.OutboxStatusSent); updateErr != nil {
-			log.Printf("[OutboxSender] 更新消息状态失败: id=%d, err=%v", msg.ID, updateErr)
+			outboxLog.ErrorContext(ctx, "更新消息状态失败", "id", msg.ID, "err", updateErr)
 		} else {
-			log.Printf("[OutboxSender] 消息发送成功: id=%d, topic=%s, key=%s", msg.ID, msg.Topic, msg.MessageKey)
+			outboxLog.InfoContext(ctx, "消息发送成功", "id", msg.ID, "topic", msg.Topic, "key", msg.MessageKey)
 		}
 		return
 	}
 
-	log.Printf("[OutboxSender] 消息发送失败: id=%d, err=%v", msg.ID, err)
+	outboxLog.ErrorContext(ctx, "消息发送失败", "id", msg.ID, "err", err)
 
 	if err := s.outboxRepo.IncrementRetryCount(ctx, msg.ID); err != nil {
-		log.Printf("[OutboxSender] 增加重试次数失败: id=%d, err=%v", msg.ID, err)
+		outboxLog.ErrorContext(ctx, "增加重试次数失败", "id", msg.ID, "err", err)
 	}
 
 	if msg.RetryCount+1 >= s.cfg.Business.MaxRetryCount {
 		if err := s.outboxRepo.MarkAsFailed(ctx, msg.ID); err != nil {
-			log.Printf("[OutboxSender] 标记消息失败状态失败: id=%d, err=%v", msg.ID, err)
+			outboxLog.ErrorContext(ctx, "标记消息失败状态失败", "id", msg.ID, "err", err)
 		} else {
-			log.Printf("[OutboxSender] 消息超过最大重试次数，标记为失败: id=%d", msg.ID)
+			outboxLog.ErrorContext(ctx, "消息超过最大重试次数，标记为失败", "id", msg.ID)
 		}
 	}
 }
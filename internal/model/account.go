@@ -4,6 +4,11 @@ import (
 	"time"
 )
 
+const (
+	AccountStatusActive = "ACTIVE"
+	AccountStatusFrozen = "FROZEN" // 冻结：不能支付，可以入账（退款、调账）
+)
+
 // Account 用户账户表
 // 记录用户的硬币余额，是整个支付系统的核心数据
 type Account struct {
@@ -12,6 +17,8 @@ type Account struct {
 	Balance      int64     `gorm:"not null;default:0" json:"balance"`       // 可用余额（硬币数）
 	FrozenAmount int64     `gorm:"not null;default:0" json:"frozen_amount"` // 冻结金额（预留，暂不使用）
 	Version      int       `gorm:"not null;default:0" json:"version"`       // 乐观锁版本号
+	FenceToken   int64     `gorm:"not null;default:0" json:"fence_token"`   // 最近一次写入携带的分布式锁 fencing token
+	Status       string    `gorm:"type:varchar(16);not null;default:ACTIVE" json:"status"`
 	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"time"
+)
+
+// ============================================================================
+// 管理后台：角色权限
+// ============================================================================
+
+const (
+	AdminRoleViewer     = "viewer"     // 只读：查订单、查审批
+	AdminRoleSupport    = "support"    // 客服：退款、冻结账户
+	AdminRoleFinance    = "finance"    // 财务：退款、调账、审批、查审计日志
+	AdminRoleSuperadmin = "superadmin" // 超级管理员：全部权限
+)
+
+// 权限，写操作的权限与审批单上的 Action 同名
+const (
+	AdminPermOrderRead      = "order.read"
+	AdminPermOrderRefund    = "order.refund"
+	AdminPermAccountAdjust  = "account.adjust"
+	AdminPermAccountFreeze  = "account.freeze"
+	AdminPermOutboxReplay   = "outbox.replay"
+	AdminPermApprovalRead   = "approval.read"
+	AdminPermApprovalReview = "approval.review"
+	AdminPermAuditRead      = "audit.read"
+	AdminPermSystemRead     = "system.read" // 查看运行状态，如后台任务的主节点
+)
+
+var viewerPermissions = []string{AdminPermOrderRead, AdminPermApprovalRead}
+
+var AdminRolePermissions = map[string][]string{
+	AdminRoleViewer:  viewerPermissions,
+	AdminRoleSupport: append([]string{AdminPermOrderRefund, AdminPermAccountFreeze}, viewerPermissions...),
+	AdminRoleFinance: append([]string{AdminPermOrderRefund, AdminPermAccountAdjust, AdminPermApprovalReview, AdminPermAuditRead}, viewerPermissions...),
+	AdminRoleSuperadmin: {
+		AdminPermOrderRead, AdminPermOrderRefund, AdminPermAccountAdjust, AdminPermAccountFreeze,
+		AdminPermOutboxReplay, AdminPermApprovalRead, AdminPermApprovalReview, AdminPermAuditRead,
+		AdminPermSystemRead,
+	},
+}
+
+func AdminHasPermission(role, perm string) bool {
+	for _, p := range AdminRolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	AdminStatusActive   = "ACTIVE"
+	AdminStatusDisabled = "DISABLED"
+)
+
+// AdminUser 管理后台用户，身份由管理后台 JWT 的 sub（用户名）确定，角色以本表为准
+type AdminUser struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Username  string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"username"`
+	Role      string    `gorm:"type:varchar(16);not null" json:"role"`
+	Status    string    `gorm:"type:varchar(16);not null;default:ACTIVE" json:"status"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (AdminUser) TableName() string {
+	return "admin_user"
+}
+
+// ============================================================================
+// 管理后台：审批单
+// ============================================================================
+
+const (
+	ApprovalStatusPending  = "PENDING"  // 待审批
+	ApprovalStatusApproved = "APPROVED" // 已通过，执行中（执行中进程崩溃会停在这里，超过执行超时后可以再次审批通过重新执行）
+	ApprovalStatusRejected = "REJECTED" // 已驳回
+	ApprovalStatusExecuted = "EXECUTED" // 已执行
+	ApprovalStatusFailed   = "FAILED"   // 执行失败，可以再次审批通过重新执行
+)
+
+// AdminApproval 审批单
+// 金额超过阈值的写操作不直接执行，而是生成审批单，由另一位有权限的管理员通过后再执行
+type AdminApproval struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ApprovalNo  string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"approval_no"`
+	Action      string     `gorm:"type:varchar(32);index;not null" json:"action"`
+	Target      string     `gorm:"type:varchar(64);not null" json:"target"` // 操作对象，如订单号、用户ID
+	Amount      int64      `gorm:"not null;default:0" json:"amount"`
+	Payload     string     `gorm:"type:text;not null" json:"payload"`
+	Reason      string     `gorm:"type:varchar(256);not null" json:"reason"`
+	Status      string     `gorm:"type:varchar(16);index;not null" json:"status"`
+	RequestedBy string     `gorm:"type:varchar(64);not null" json:"requested_by"`
+	ReviewedBy  string     `gorm:"type:varchar(64)" json:"reviewed_by"`
+	ReviewNote  string     `gorm:"type:varchar(256)" json:"review_note"`
+	Result      string     `gorm:"type:text" json:"result"` // 执行结果或失败原因
+	ReviewedAt  *time.Time `json:"reviewed_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (AdminApproval) TableName() string {
+	return "admin_approval"
+}
+
+// ============================================================================
+// 管理后台：审计日志
+// ============================================================================
+
+const (
+	AuditResultSuccess         = "SUCCESS"
+	AuditResultFailed          = "FAILED"
+	AuditResultPendingApproval = "PENDING_APPROVAL"
+	AuditResultRejected        = "REJECTED"
+)
+
+// AdminAuditLog 管理后台审计日志
+// 只追加，不修改，不删除（没有 UpdatedAt，仓储层也不提供更新/删除方法）
+type AdminAuditLog struct {
+	ID         int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Operator   string    `gorm:"type:varchar(64);index;not null" json:"operator"`
+	Role       string    `gorm:"type:varchar(16);not null" json:"role"`
+	Action     string    `gorm:"type:varchar(32);index;not null" json:"action"`
+	Target     string    `gorm:"type:varchar(64);index" json:"target"`
+	ApprovalNo string    `gorm:"type:varchar(64);index" json:"approval_no"` // 审批单号；无需审批的操作为操作单号，同时是退款/调账的幂等ID
+	Payload    string    `gorm:"type:text" json:"payload"`
+	Result     string    `gorm:"type:varchar(20);not null" json:"result"`
+	Message    string    `gorm:"type:varchar(512)" json:"message"`
+	ClientIP   string    `gorm:"type:varchar(64)" json:"client_ip"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+func (AdminAuditLog) TableName() string {
+	return "admin_audit_log"
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// 调用方应用权限
+const (
+	ScopePay      = "pay"      // 创建订单、支付、取消订单
+	ScopeRefund   = "refund"   // 退款
+	ScopeRecharge = "recharge" // 充值
+	ScopeRead     = "read"     // 查询余额、订单
+)
+
+// 应用状态
+const (
+	AppStatusActive   = "ACTIVE"
+	AppStatusDisabled = "DISABLED"
+)
+
+// AppCredential 调用方应用凭证
+// 服务间调用使用 app_id + secret 对请求做 HMAC 签名，secret 需要参与签名计算，因此保存原文，
+// 该表应只有支付服务自己的账号可读
+type AppCredential struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	AppID     string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"app_id"`
+	Name      string    `gorm:"type:varchar(128);not null" json:"name"`
+	Secret    string    `gorm:"type:varchar(128);not null" json:"-"`
+	Scopes    string    `gorm:"type:varchar(255);not null" json:"scopes"` // 逗号分隔，如 pay,refund,read
+	Status    string    `gorm:"type:varchar(16);not null;default:ACTIVE" json:"status"`
+	Trusted   bool      `gorm:"not null;default:false" json:"trusted"` // 可信服务：可以不带用户令牌代任意用户操作
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (AppCredential) TableName() string {
+	return "app_credential"
+}
+
+// HasScope 是否拥有指定权限
+func (a *AppCredential) HasScope(scope string) bool {
+	for _, s := range strings.Split(a.Scopes, ",") {
+		if strings.TrimSpace(s) == scope {
+			return true
+		}
+	}
+	return false
+}
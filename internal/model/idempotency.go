@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+// 幂等记录状态
+const (
+	IdempotencyStatusProcessing = "PROCESSING" // 首次请求处理中
+	IdempotencyStatusCompleted  = "COMPLETED"  // 已处理，保存了响应
+)
+
+// IdempotencyRecord 接口幂等记录（Redis 不可用时的兜底存储）
+// Key 由 调用方 + 幂等键 + 方法 + 路由 组成，RequestHash 为请求体摘要，用于识别"同一个键、不同请求"
+type IdempotencyRecord struct {
+	ID           int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Key          string    `gorm:"column:idem_key;type:varchar(255);uniqueIndex;not null" json:"key"`
+	RequestHash  string    `gorm:"type:varchar(64);not null" json:"request_hash"`
+	Status       string    `gorm:"type:varchar(16);not null" json:"status"`
+	StatusCode   int       `gorm:"not null;default:0" json:"status_code"`
+	ContentType  string    `gorm:"type:varchar(128)" json:"content_type"`
+	ResponseBody string    `gorm:"type:mediumtext" json:"response_body"`
+	ExpiresAt    time.Time `gorm:"index;not null" json:"expires_at"` // 处理中：处理超时时间；已完成：保留截止时间
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_record"
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+// InboxMessage 消息收件箱（消费去重表）
+// 上游命令处理成功后，在同一个事务里写入一条记录，重复投递的消息据此跳过
+type InboxMessage struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageID   string    `gorm:"type:varchar(128);uniqueIndex;not null" json:"message_id"` // 消息唯一ID
+	Topic       string    `gorm:"type:varchar(64);not null" json:"topic"`
+	CommandType string    `gorm:"type:varchar(64);not null" json:"command_type"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+func (InboxMessage) TableName() string {
+	return "inbox_message"
+}
@@ -14,6 +14,8 @@ type OutboxMessage struct {
 	ID         int64     `gorm:"primaryKey;autoIncrement" json:"id"`
 	MessageKey string    `gorm:"type:varchar(64);not null" json:"message_key"`
 	Topic      string    `gorm:"type:varchar(64);not null" json:"topic"`
+	EventType  string    `gorm:"type:varchar(64);index" json:"event_type"` // 事件类型，见 event 包
+	Headers    string    `gorm:"type:text" json:"headers"`                 // Kafka 消息头（JSON）
 	Payload    string    `gorm:"type:text;not null" json:"payload"`
 	Status     string    `gorm:"type:varchar(20);index;not null;default:PENDING" json:"status"`
 	RetryCount int       `gorm:"not null;default:0" json:"retry_count"`
@@ -24,3 +26,23 @@ type OutboxMessage struct {
 func (OutboxMessage) TableName() string {
 	return "outbox_message"
 }
+
+// OutboxMessageArchive 已发送消息归档表
+// 字段与 outbox_message 一致，ID 沿用原消息ID，便于排查时对照
+type OutboxMessageArchive struct {
+	ID         int64     `gorm:"primaryKey;autoIncrement:false" json:"id"`
+	MessageKey string    `gorm:"type:varchar(64);not null" json:"message_key"`
+	Topic      string    `gorm:"type:varchar(64);not null" json:"topic"`
+	EventType  string    `gorm:"type:varchar(64)" json:"event_type"`
+	Headers    string    `gorm:"type:text" json:"headers"`
+	Payload    string    `gorm:"type:text;not null" json:"payload"`
+	Status     string    `gorm:"type:varchar(20);not null" json:"status"`
+	RetryCount int       `gorm:"not null;default:0" json:"retry_count"`
+	CreatedAt  time.Time `gorm:"autoCreateTime:false;index" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime:false" json:"updated_at"`
+	ArchivedAt time.Time `gorm:"autoCreateTime" json:"archived_at"`
+}
+
+func (OutboxMessageArchive) TableName() string {
+	return "outbox_message_archive"
+}
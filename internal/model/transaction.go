@@ -9,9 +9,11 @@ import (
 // ============================================================================
 
 const (
-	TransactionTypeRecharge = "RECHARGE" // 充值
-	TransactionTypePay      = "PAY"      // 支付（扣款）
-	TransactionTypeRefund   = "REFUND"   // 退款
+	TransactionTypeRecharge   = "RECHARGE"   // 充值
+	TransactionTypePay        = "PAY"        // 支付（扣款）
+	TransactionTypeRefund     = "REFUND"     // 退款
+	TransactionTypeReward     = "REWARD"     // 奖励发放（如活动平台发放硬币）
+	TransactionTypeAdjustment = "ADJUSTMENT" // 人工调账（正数加款，负数扣款），需经审批
 )
 
 // ============================================================================
@@ -25,16 +27,17 @@ const (
 // 1. 只追加，不修改，不删除 —— 保证审计可追溯
 // 2. 每笔流水必须关联订单号 —— 便于对账
 // 3. 记录交易前后余额 —— 便于校验余额一致性
+// 4. 同一订单号同一类型的流水只有一条（唯一索引）—— 重复执行不会重复入账
 type AccountTransaction struct {
 	ID            int64     `gorm:"primaryKey;autoIncrement" json:"id"`
-	TransactionNo string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"transaction_no"` // 流水号（全局唯一）
-	UserID        int64     `gorm:"index;not null" json:"user_id"`                               // 用户ID
-	OrderNo       string    `gorm:"type:varchar(64);index;not null" json:"order_no"`             // 关联订单号
-	Amount        int64     `gorm:"not null" json:"amount"`                                      // 金额（正数入账，负数出账）
-	Type          string    `gorm:"type:varchar(20);not null" json:"type"`                       // 交易类型
-	BalanceBefore int64     `gorm:"not null" json:"balance_before"`                              // 交易前余额
-	BalanceAfter  int64     `gorm:"not null" json:"balance_after"`                               // 交易后余额
-	Remark        string    `gorm:"type:varchar(256)" json:"remark"`                             // 备注
+	TransactionNo string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"transaction_no"`                       // 流水号（全局唯一）
+	UserID        int64     `gorm:"index;not null" json:"user_id"`                                                     // 用户ID
+	OrderNo       string    `gorm:"type:varchar(64);uniqueIndex:uk_order_no_type,priority:1;not null" json:"order_no"` // 关联订单号
+	Amount        int64     `gorm:"not null" json:"amount"`                                                            // 金额（正数入账，负数出账）
+	Type          string    `gorm:"type:varchar(20);uniqueIndex:uk_order_no_type,priority:2;not null" json:"type"`     // 交易类型
+	BalanceBefore int64     `gorm:"not null" json:"balance_before"`                                                    // 交易前余额
+	BalanceAfter  int64     `gorm:"not null" json:"balance_after"`                                                     // 交易后余额
+	Remark        string    `gorm:"type:varchar(256)" json:"remark"`                                                   // 备注
 	CreatedAt     time.Time `gorm:"autoCreateTime;index" json:"created_at"`
 }
 
@@ -3,17 +3,21 @@ package repository
 import (
 	"context"
 	"errors"
+	"net/http"
 
 	"paysystem/internal/model"
+	"paysystem/pkg/errs"
+	"paysystem/pkg/response"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 var (
-	ErrAccountNotFound  = errors.New("账户不存在")
-	ErrBalanceNotEnough = errors.New("余额不足")
-	ErrOptimisticLock   = errors.New("乐观锁冲突，请重试")
+	ErrAccountNotFound  = errs.New(response.CodeAccountNotFound, "account.not_found", http.StatusNotFound, false, "账户不存在")
+	ErrBalanceNotEnough = errs.New(response.CodeBalanceNotEnough, "account.balance_not_enough", http.StatusUnprocessableEntity, false, "余额不足")
+	ErrOptimisticLock   = errs.New(response.CodeSystemBusy, "account.concurrent_update", http.StatusConflict, true, "乐观锁冲突，请重试")
+	ErrStaleFenceToken  = errs.New(response.CodeSystemBusy, "account.stale_fence_token", http.StatusConflict, true, "锁已失效，拒绝写入")
 )
 
 type AccountRepository struct {
@@ -55,14 +59,26 @@ func (r *AccountRepository) GetByUserIDForUpdate(ctx context.Context, tx *gorm.D
 	return &account, nil
 }
 
-func (r *AccountRepository) Deduct(ctx context.Context, tx *gorm.DB, userID int64, amount int64, version int) error {
-	result := tx.WithContext(ctx).
+// Deduct 扣减余额
+//
+// fenceToken 为持有分布式锁时获得的 fencing token（大于 0 时生效）：
+// 写入时记录该 token，token 比账户上记录的更小时拒绝写入，返回 ErrStaleFenceToken。
+// 不在分布式锁保护下的写入传 0，不参与校验。
+func (r *AccountRepository) Deduct(ctx context.Context, tx *gorm.DB, userID int64, amount int64, version int, fenceToken int64) error {
+	query := tx.WithContext(ctx).
 		Model(&model.Account{}).
-		Where("user_id = ? AND balance >= ? AND version = ?", userID, amount, version).
-		Updates(map[string]interface{}{
-			"balance": gorm.Expr("balance - ?", amount),
-			"version": gorm.Expr("version + 1"),
-		})
+		Where("user_id = ? AND balance >= ? AND version = ?", userID, amount, version)
+
+	updates := map[string]interface{}{
+		"balance": gorm.Expr("balance - ?", amount),
+		"version": gorm.Expr("version + 1"),
+	}
+	if fenceToken > 0 {
+		query = query.Where("fence_token <= ?", fenceToken)
+		updates["fence_token"] = fenceToken
+	}
+
+	result := query.Updates(updates)
 
 	if result.Error != nil {
 		return result.Error
@@ -73,6 +89,9 @@ func (r *AccountRepository) Deduct(ctx context.Context, tx *gorm.DB, userID int6
 		if err != nil {
 			return err
 		}
+		if fenceToken > 0 && account.FenceToken > fenceToken {
+			return ErrStaleFenceToken
+		}
 		if account.Balance < amount {
 			return ErrBalanceNotEnough
 		}
@@ -82,20 +101,33 @@ func (r *AccountRepository) Deduct(ctx context.Context, tx *gorm.DB, userID int6
 	return nil
 }
 
-func (r *AccountRepository) Increase(ctx context.Context, tx *gorm.DB, userID int64, amount int64) error {
-	result := tx.WithContext(ctx).
+// Increase 增加余额，fenceToken 含义同 Deduct
+func (r *AccountRepository) Increase(ctx context.Context, tx *gorm.DB, userID int64, amount int64, fenceToken int64) error {
+	query := tx.WithContext(ctx).
 		Model(&model.Account{}).
-		Where("user_id = ?", userID).
-		Updates(map[string]interface{}{
-			"balance": gorm.Expr("balance + ?", amount),
-			"version": gorm.Expr("version + 1"),
-		})
+		Where("user_id = ?", userID)
+
+	updates := map[string]interface{}{
+		"balance": gorm.Expr("balance + ?", amount),
+		"version": gorm.Expr("version + 1"),
+	}
+	if fenceToken > 0 {
+		query = query.Where("fence_token <= ?", fenceToken)
+		updates["fence_token"] = fenceToken
+	}
+
+	result := query.Updates(updates)
 
 	if result.Error != nil {
 		return result.Error
 	}
 
 	if result.RowsAffected == 0 {
+		if fenceToken > 0 {
+			if _, err := r.GetByUserID(ctx, userID); err == nil {
+				return ErrStaleFenceToken
+			}
+		}
 		return ErrAccountNotFound
 	}
 
@@ -130,3 +162,20 @@ func (r *AccountRepository) GetOrCreate(ctx context.Context, userID int64) (*mod
 
 	return r.GetByUserID(ctx, userID)
 }
+
+// UpdateStatus 修改账户状态（冻结/解冻）
+func (r *AccountRepository) UpdateStatus(ctx context.Context, userID int64, status string) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.Account{}).
+		Where("user_id = ?", userID).
+		Update("status", status)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if _, err := r.GetByUserID(ctx, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
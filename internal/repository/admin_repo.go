@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"paysystem/internal/model"
+	"paysystem/pkg/errs"
+	"paysystem/pkg/response"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAdminNotFound          = errs.New(response.CodeUnauthorized, "admin.not_found", http.StatusUnauthorized, false, "管理员不存在")
+	ErrApprovalNotFound       = errs.New(response.CodeNotFound, "admin.approval_not_found", http.StatusNotFound, false, "审批单不存在")
+	ErrApprovalStatusConflict = errs.New(response.CodeBusinessError, "admin.approval_status_changed", http.StatusConflict, false, "审批单状态已变更")
+)
+
+// AdminRepository 管理后台：管理员、审批单、审计日志
+type AdminRepository struct {
+	db *gorm.DB
+}
+
+func NewAdminRepository(db *gorm.DB) *AdminRepository {
+	return &AdminRepository{db: db}
+}
+
+func (r *AdminRepository) GetUserByUsername(ctx context.Context, username string) (*model.AdminUser, error) {
+	var user model.AdminUser
+	err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAdminNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *AdminRepository) CreateApproval(ctx context.Context, tx *gorm.DB, approval *model.AdminApproval) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.WithContext(ctx).Create(approval).Error
+}
+
+func (r *AdminRepository) GetApproval(ctx context.Context, approvalNo string) (*model.AdminApproval, error) {
+	var approval model.AdminApproval
+	err := r.db.WithContext(ctx).Where("approval_no = ?", approvalNo).First(&approval).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrApprovalNotFound
+		}
+		return nil, err
+	}
+	return &approval, nil
+}
+
+func (r *AdminRepository) ListApprovals(ctx context.Context, status string, page, pageSize int) ([]*model.AdminApproval, int64, error) {
+	var approvals []*model.AdminApproval
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&model.AdminApproval{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&approvals).Error
+	return approvals, total, err
+}
+
+// Review 审批：按读到的状态和更新时间比较交换，改为通过/驳回；
+// 期间被别人处理过（状态或更新时间已变）时返回 ErrApprovalStatusConflict，同一次审批不会被执行两次
+func (r *AdminRepository) Review(ctx context.Context, approval *model.AdminApproval, toStatus, reviewer, note string) error {
+	now := time.Now()
+	result := r.db.WithContext(ctx).
+		Model(&model.AdminApproval{}).
+		Where("approval_no = ? AND status = ? AND updated_at = ?", approval.ApprovalNo, approval.Status, approval.UpdatedAt).
+		Updates(map[string]interface{}{
+			"status":      toStatus,
+			"reviewed_by": reviewer,
+			"review_note": note,
+			"reviewed_at": &now,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrApprovalStatusConflict
+	}
+	return nil
+}
+
+// FinishApproval 记录执行结果：已通过 -> 已执行/执行失败
+func (r *AdminRepository) FinishApproval(ctx context.Context, approvalNo, toStatus, result string) error {
+	return r.db.WithContext(ctx).
+		Model(&model.AdminApproval{}).
+		Where("approval_no = ? AND status = ?", approvalNo, model.ApprovalStatusApproved).
+		Updates(map[string]interface{}{
+			"status": toStatus,
+			"result": result,
+		}).Error
+}
+
+// CreateAuditLog 写入审计日志（审计日志只追加，不提供更新和删除）
+func (r *AdminRepository) CreateAuditLog(ctx context.Context, log *model.AdminAuditLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
+
+// AuditLogFilter 审计日志查询条件，零值字段不过滤
+type AuditLogFilter struct {
+	Operator string
+	Action   string
+	Target   string
+}
+
+func (r *AdminRepository) ListAuditLogs(ctx context.Context, filter *AuditLogFilter, page, pageSize int) ([]*model.AdminAuditLog, int64, error) {
+	var logs []*model.AdminAuditLog
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&model.AdminAuditLog{})
+	if filter.Operator != "" {
+		query = query.Where("operator = ?", filter.Operator)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Target != "" {
+		query = query.Where("target = ?", filter.Target)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&logs).Error
+	return logs, total, err
+}
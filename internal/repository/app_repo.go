@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"paysystem/internal/model"
+	"paysystem/pkg/errs"
+	"paysystem/pkg/response"
+
+	"gorm.io/gorm"
+)
+
+var ErrAppNotFound = errs.New(response.CodeUnauthorized, "auth.app_not_found", http.StatusUnauthorized, false, "应用不存在")
+
+type AppRepository struct {
+	db *gorm.DB
+}
+
+func NewAppRepository(db *gorm.DB) *AppRepository {
+	return &AppRepository{db: db}
+}
+
+func (r *AppRepository) GetByAppID(ctx context.Context, appID string) (*model.AppCredential, error) {
+	var app model.AppCredential
+	err := r.db.WithContext(ctx).Where("app_id = ?", appID).First(&app).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAppNotFound
+		}
+		return nil, err
+	}
+	return &app, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"paysystem/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Begin 占用幂等键：键不存在或已过期时写入处理中记录并返回 true，否则返回 false
+func (r *IdempotencyRepository) Begin(ctx context.Context, record *model.IdempotencyRecord) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(record)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// 已存在但过期（处理超时或超过保留时间），接管该记录
+	result = r.db.WithContext(ctx).
+		Model(&model.IdempotencyRecord{}).
+		Where("idem_key = ? AND expires_at < ?", record.Key, time.Now()).
+		Updates(map[string]interface{}{
+			"request_hash":  record.RequestHash,
+			"status":        record.Status,
+			"status_code":   0,
+			"content_type":  "",
+			"response_body": "",
+			"expires_at":    record.ExpiresAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *IdempotencyRepository) GetByKey(ctx context.Context, key string) (*model.IdempotencyRecord, error) {
+	var record model.IdempotencyRecord
+	err := r.db.WithContext(ctx).Where("idem_key = ?", key).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Complete 保存响应，记录变为已完成
+func (r *IdempotencyRepository) Complete(ctx context.Context, record *model.IdempotencyRecord) error {
+	return r.db.WithContext(ctx).
+		Model(&model.IdempotencyRecord{}).
+		Where("idem_key = ? AND request_hash = ?", record.Key, record.RequestHash).
+		Updates(map[string]interface{}{
+			"status":        model.IdempotencyStatusCompleted,
+			"status_code":   record.StatusCode,
+			"content_type":  record.ContentType,
+			"response_body": record.ResponseBody,
+			"expires_at":    record.ExpiresAt,
+		}).Error
+}
+
+// Delete 删除处理中的记录，释放幂等键（请求处理失败，允许客户端重试）
+func (r *IdempotencyRepository) Delete(ctx context.Context, key, requestHash string) error {
+	return r.db.WithContext(ctx).
+		Where("idem_key = ? AND request_hash = ? AND status = ?", key, requestHash, model.IdempotencyStatusProcessing).
+		Delete(&model.IdempotencyRecord{}).Error
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+
+	"paysystem/internal/model"
+	"paysystem/pkg/errs"
+	"paysystem/pkg/response"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrDuplicateMessage = errs.New(response.CodeDuplicateRequest, "message.duplicate", http.StatusConflict, false, "消息已处理")
+
+type InboxRepository struct {
+	db *gorm.DB
+}
+
+func NewInboxRepository(db *gorm.DB) *InboxRepository {
+	return &InboxRepository{db: db}
+}
+
+// Create 写入收件箱记录，消息ID已存在时返回 ErrDuplicateMessage
+func (r *InboxRepository) Create(ctx context.Context, tx *gorm.DB, msg *model.InboxMessage) error {
+	if tx == nil {
+		tx = r.db
+	}
+	result := tx.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(msg)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrDuplicateMessage
+	}
+
+	return nil
+}
+
+func (r *InboxRepository) Exists(ctx context.Context, messageID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.InboxMessage{}).
+		Where("message_id = ?", messageID).
+		Count(&count).Error
+	return count > 0, err
+}
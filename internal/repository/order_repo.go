@@ -3,17 +3,21 @@ package repository
 import (
 	"context"
 	"errors"
+	"net/http"
 	"time"
 
 	"paysystem/internal/model"
+	"paysystem/pkg/errs"
+	"paysystem/pkg/response"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
-	ErrOrderNotFound      = errors.New("订单不存在")
-	ErrOrderStatusInvalid = errors.New("订单状态不合法")
-	ErrDuplicateRequest   = errors.New("重复请求")
+	ErrOrderNotFound      = errs.New(response.CodeOrderNotFound, "order.not_found", http.StatusNotFound, false, "订单不存在")
+	ErrOrderStatusInvalid = errs.New(response.CodeOrderStatusInvalid, "order.status_invalid", http.StatusConflict, false, "订单状态不合法")
+	ErrDuplicateRequest   = errs.New(response.CodeDuplicateRequest, "order.duplicate_request", http.StatusConflict, false, "重复请求")
 )
 
 type OrderRepository struct {
@@ -43,6 +47,21 @@ func (r *OrderRepository) GetByOrderNo(ctx context.Context, orderNo string) (*mo
 	return &order, nil
 }
 
+func (r *OrderRepository) GetByOrderNoForUpdate(ctx context.Context, tx *gorm.DB, orderNo string) (*model.PayOrder, error) {
+	var order model.PayOrder
+	err := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_no = ?", orderNo).
+		First(&order).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
 func (r *OrderRepository) GetByRequestID(ctx context.Context, requestID string) (*model.PayOrder, error) {
 	var order model.PayOrder
 	err := r.db.WithContext(ctx).Where("request_id = ?", requestID).First(&order).Error
@@ -126,3 +145,49 @@ func (r *OrderRepository) ListByUserID(ctx context.Context, userID int64, page,
 
 	return orders, total, err
 }
+
+// OrderSearchFilter 订单搜索条件，零值字段不过滤
+type OrderSearchFilter struct {
+	OrderNo   string
+	RequestID string
+	UserID    int64
+	Status    string
+	StartTime *time.Time
+	EndTime   *time.Time
+}
+
+func (r *OrderRepository) Search(ctx context.Context, filter *OrderSearchFilter, page, pageSize int) ([]*model.PayOrder, int64, error) {
+	var orders []*model.PayOrder
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&model.PayOrder{})
+	if filter.OrderNo != "" {
+		query = query.Where("order_no = ?", filter.OrderNo)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.UserID > 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.StartTime != nil {
+		query = query.Where("created_at >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("created_at < ?", *filter.EndTime)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&orders).Error
+	return orders, total, err
+}
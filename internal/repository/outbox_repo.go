@@ -2,10 +2,12 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"paysystem/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OutboxRepository struct {
@@ -33,6 +35,23 @@ func (r *OutboxRepository) GetPendingMessages(ctx context.Context, limit int) ([
 	return messages, err
 }
 
+// PendingStats 待发送消息数和最早一条的创建时间（没有待发送消息时返回零值时间）
+func (r *OutboxRepository) PendingStats(ctx context.Context) (int64, time.Time, error) {
+	var row struct {
+		Total  int64
+		Oldest *time.Time
+	}
+	err := r.db.WithContext(ctx).
+		Model(&model.OutboxMessage{}).
+		Select("COUNT(*) AS total, MIN(created_at) AS oldest").
+		Where("status = ?", model.OutboxStatusPending).
+		Scan(&row).Error
+	if err != nil || row.Oldest == nil {
+		return row.Total, time.Time{}, err
+	}
+	return row.Total, *row.Oldest, nil
+}
+
 func (r *OutboxRepository) UpdateStatus(ctx context.Context, id int64, status string) error {
 	return r.db.WithContext(ctx).
 		Model(&model.OutboxMessage{}).
@@ -66,3 +85,85 @@ func (r *OutboxRepository) GetFailedMessages(ctx context.Context, limit int) ([]
 		Find(&messages).Error
 	return messages, err
 }
+
+// GetSentBefore 查询指定时间之前创建的已发送消息
+// topics 非空时只查这些主题；excludeTopics 非空时排除这些主题
+func (r *OutboxRepository) GetSentBefore(ctx context.Context, topics, excludeTopics []string, before time.Time, limit int) ([]*model.OutboxMessage, error) {
+	var messages []*model.OutboxMessage
+	query := r.db.WithContext(ctx).
+		Where("status = ? AND created_at < ?", model.OutboxStatusSent, before)
+	if len(topics) > 0 {
+		query = query.Where("topic IN ?", topics)
+	}
+	if len(excludeTopics) > 0 {
+		query = query.Where("topic NOT IN ?", excludeTopics)
+	}
+	err := query.
+		Order("id ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// Archive 将消息移入归档表，写归档和删除原消息在同一个事务里
+func (r *OutboxRepository) Archive(ctx context.Context, messages []*model.OutboxMessage) (int64, error) {
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	archives := make([]*model.OutboxMessageArchive, 0, len(messages))
+	ids := make([]int64, 0, len(messages))
+	for _, msg := range messages {
+		archives = append(archives, &model.OutboxMessageArchive{
+			ID:         msg.ID,
+			MessageKey: msg.MessageKey,
+			Topic:      msg.Topic,
+			EventType:  msg.EventType,
+			Headers:    msg.Headers,
+			Payload:    msg.Payload,
+			Status:     msg.Status,
+			RetryCount: msg.RetryCount,
+			CreatedAt:  msg.CreatedAt,
+			UpdatedAt:  msg.UpdatedAt,
+		})
+		ids = append(ids, msg.ID)
+	}
+
+	var archived int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 重复归档（上一轮写归档成功但删除前进程退出）时忽略冲突
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&archives).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id IN ? AND status = ?", ids, model.OutboxStatusSent).Delete(&model.OutboxMessage{})
+		if result.Error != nil {
+			return result.Error
+		}
+		archived = result.RowsAffected
+		return nil
+	})
+	return archived, err
+}
+
+// DeleteSent 删除已发送消息
+func (r *OutboxRepository) DeleteSent(ctx context.Context, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.WithContext(ctx).
+		Where("id IN ? AND status = ?", ids, model.OutboxStatusSent).
+		Delete(&model.OutboxMessage{})
+	return result.RowsAffected, result.Error
+}
+
+// Replay 重新投递：把失败或已发送的消息重置为待发送，返回重置条数
+func (r *OutboxRepository) Replay(ctx context.Context, ids []int64) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.OutboxMessage{}).
+		Where("id IN ? AND status IN ?", ids, []string{model.OutboxStatusFailed, model.OutboxStatusSent}).
+		Updates(map[string]interface{}{
+			"status":      model.OutboxStatusPending,
+			"retry_count": 0,
+		})
+	return result.RowsAffected, result.Error
+}
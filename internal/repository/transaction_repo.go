@@ -80,3 +80,22 @@ func (r *TransactionRepository) GetByUserIDAndOrderNo(ctx context.Context, userI
 	}
 	return &trans, nil
 }
+
+// GetByOrderNoAndType 查询订单某类型的流水（同一订单可能同时有支付和退款流水），不存在返回 nil
+// tx 不为空时在事务内查询，用于加锁后的重复检查
+func (r *TransactionRepository) GetByOrderNoAndType(ctx context.Context, tx *gorm.DB, userID int64, orderNo, transType string) (*model.AccountTransaction, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var trans model.AccountTransaction
+	err := tx.WithContext(ctx).
+		Where("user_id = ? AND order_no = ? AND type = ?", userID, orderNo, transType).
+		First(&trans).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &trans, nil
+}
@@ -3,22 +3,41 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
+	"paysystem/internal/config"
+	"paysystem/internal/event"
+	"paysystem/internal/infrastructure/lock"
+	"paysystem/internal/infrastructure/logging"
 	"paysystem/internal/model"
 	"paysystem/internal/repository"
+	"paysystem/pkg/errs"
+	"paysystem/pkg/idgen"
 
 	"gorm.io/gorm"
 )
 
+var accountLog = logging.For("AccountService")
+
 type AccountService struct {
-	accountRepo *repository.AccountRepository
-	db          *gorm.DB
+	accountRepo     *repository.AccountRepository
+	transactionRepo *repository.TransactionRepository
+	outboxRepo      *repository.OutboxRepository
+	locker          lock.Locker
+	cfg             *config.Config
+	db              *gorm.DB
 }
 
-func NewAccountService(db *gorm.DB) *AccountService {
+func NewAccountService(db *gorm.DB, locker lock.Locker, cfg *config.Config) *AccountService {
 	return &AccountService{
-		accountRepo: repository.NewAccountRepository(db),
-		db:          db,
+		accountRepo:     repository.NewAccountRepository(db),
+		transactionRepo: repository.NewTransactionRepository(db),
+		outboxRepo:      repository.NewOutboxRepository(db),
+		locker:          locker,
+		cfg:             cfg,
+		db:              db,
 	}
 }
 
@@ -39,7 +58,7 @@ func (s *AccountService) GetAccount(ctx context.Context, userID int64) (*model.A
 
 func (s *AccountService) Recharge(ctx context.Context, userID int64, amount int64) error {
 	if amount <= 0 {
-		return errors.New("充值金额必须大于0")
+		return ErrInvalidAmount
 	}
 
 	_, err := s.accountRepo.GetOrCreate(ctx, userID)
@@ -47,5 +66,192 @@ func (s *AccountService) Recharge(ctx context.Context, userID int64, amount int6
 		return err
 	}
 
-	return s.accountRepo.Increase(ctx, s.db, userID, amount)
+	return s.accountRepo.Increase(ctx, s.db, userID, amount, 0)
+}
+
+type GrantCoinsRequest struct {
+	RequestID string
+	UserID    int64
+	Amount    int64
+	Reason    string
+}
+
+// GrantCoins 发放硬币（入账 + 记录流水），在调用方传入的事务中执行
+// 相同 RequestID 只发放一次：上游换了消息ID重新发送同一个请求时，收件箱去重挡不住，按奖励流水去重
+func (s *AccountService) GrantCoins(ctx context.Context, tx *gorm.DB, req *GrantCoinsRequest) error {
+	if req.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+
+	if _, err := s.accountRepo.GetOrCreate(ctx, req.UserID); err != nil {
+		return fmt.Errorf("获取账户信息失败: %w", err)
+	}
+
+	account, err := s.accountRepo.GetByUserIDForUpdate(ctx, tx, req.UserID)
+	if err != nil {
+		return fmt.Errorf("查询账户失败: %w", err)
+	}
+
+	// 锁住账户行后检查，并发的同一请求也只有一个能入账（流水的唯一索引兜底）
+	existing, err := s.transactionRepo.GetByOrderNoAndType(ctx, tx, req.UserID, req.RequestID, model.TransactionTypeReward)
+	if err != nil {
+		return fmt.Errorf("查询流水失败: %w", err)
+	}
+	if existing != nil {
+		accountLog.InfoContext(ctx, "硬币已发放，跳过", "request_id", req.RequestID, "user_id", req.UserID)
+		return nil
+	}
+
+	if err := s.accountRepo.Increase(ctx, tx, req.UserID, req.Amount, 0); err != nil {
+		return fmt.Errorf("发放到账失败: %w", err)
+	}
+
+	transaction := &model.AccountTransaction{
+		TransactionNo: idgen.GenerateTransactionNo(),
+		UserID:        req.UserID,
+		OrderNo:       req.RequestID,
+		Amount:        req.Amount,
+		Type:          model.TransactionTypeReward,
+		BalanceBefore: account.Balance,
+		BalanceAfter:  account.Balance + req.Amount,
+		Remark:        fmt.Sprintf("奖励-%s", req.Reason),
+	}
+	if err := s.transactionRepo.Create(ctx, tx, transaction); err != nil {
+		return fmt.Errorf("记录流水失败: %w", err)
+	}
+
+	return nil
+}
+
+type AdjustBalanceRequest struct {
+	AdjustmentNo string // 调账单号（审批单号），同时是幂等ID
+	UserID       int64
+	Amount       int64 // 正数加款，负数扣款
+	Reason       string
+	RequestedBy  string // 发起人
+	ApprovedBy   string // 审批人，必须与发起人不同
+}
+
+// AdjustBalance 人工调账入账，相同 AdjustmentNo 只执行一次
+//
+// 与支付走同一条路径：持有用户的支付锁（带 fencing token）-> 事务内锁账户行
+// -> 改余额 + 记录 ADJUSTMENT 流水 + 写 outbox 事件
+func (s *AccountService) AdjustBalance(ctx context.Context, req *AdjustBalanceRequest) (*model.AccountTransaction, error) {
+	if req.Amount == 0 {
+		return nil, errs.ErrInvalidParam.WithDetail("amount")
+	}
+	if req.ApprovedBy == "" || req.ApprovedBy == req.RequestedBy {
+		return nil, ErrSelfApproval
+	}
+
+	existing, err := s.transactionRepo.GetByOrderNoAndType(ctx, nil, req.UserID, req.AdjustmentNo, model.TransactionTypeAdjustment)
+	if err != nil {
+		return nil, fmt.Errorf("查询流水失败: %w", err)
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	if _, err := s.accountRepo.GetOrCreate(ctx, req.UserID); err != nil {
+		return nil, fmt.Errorf("获取账户信息失败: %w", err)
+	}
+
+	adjustLock := s.locker.NewLock(lock.Resource{
+		Key: lock.PayLockKey(req.UserID),
+		RowLock: func(ctx context.Context, tx *gorm.DB) error {
+			_, err := s.accountRepo.GetByUserIDForUpdate(ctx, tx, req.UserID)
+			return err
+		},
+	}, req.AdjustmentNo, 30*time.Second)
+	fenceToken, err := adjustLock.Lock(ctx, 100*time.Millisecond, 30)
+	if err != nil {
+		return nil, fmt.Errorf("系统繁忙，请稍后重试: %w", err)
+	}
+	defer adjustLock.Unlock(ctx)
+	ctx = adjustLock.StartWatchdog(ctx)
+
+	var transaction *model.AccountTransaction
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := adjustLock.LockInTx(ctx, tx); err != nil {
+			return fmt.Errorf("锁定账户失败: %w", err)
+		}
+
+		account, err := s.accountRepo.GetByUserIDForUpdate(ctx, tx, req.UserID)
+		if err != nil {
+			return fmt.Errorf("查询账户失败: %w", err)
+		}
+
+		// 锁住账户行后再查一次：加锁前的检查挡不住并发执行的同一调账
+		existing, err := s.transactionRepo.GetByOrderNoAndType(ctx, tx, req.UserID, req.AdjustmentNo, model.TransactionTypeAdjustment)
+		if err != nil {
+			return fmt.Errorf("查询流水失败: %w", err)
+		}
+		if existing != nil {
+			transaction = existing
+			return nil
+		}
+
+		if req.Amount > 0 {
+			err = s.accountRepo.Increase(ctx, tx, req.UserID, req.Amount, fenceToken)
+		} else {
+			err = s.accountRepo.Deduct(ctx, tx, req.UserID, -req.Amount, account.Version, fenceToken)
+		}
+		if err != nil {
+			return fmt.Errorf("调账失败: %w", err)
+		}
+
+		transaction = &model.AccountTransaction{
+			TransactionNo: idgen.GenerateTransactionNo(),
+			UserID:        req.UserID,
+			OrderNo:       req.AdjustmentNo,
+			Amount:        req.Amount,
+			Type:          model.TransactionTypeAdjustment,
+			BalanceBefore: account.Balance,
+			BalanceAfter:  account.Balance + req.Amount,
+			Remark:        fmt.Sprintf("调账-%s", req.Reason),
+		}
+		if err := s.transactionRepo.Create(ctx, tx, transaction); err != nil {
+			return fmt.Errorf("记录流水失败: %w", err)
+		}
+
+		adjustedEvent := event.NewAccountAdjusted(&event.AccountAdjustedData{
+			AdjustmentNo:  req.AdjustmentNo,
+			TransactionNo: transaction.TransactionNo,
+			UserID:        req.UserID,
+			Amount:        req.Amount,
+			BalanceBefore: transaction.BalanceBefore,
+			BalanceAfter:  transaction.BalanceAfter,
+			Reason:        req.Reason,
+			RequestedBy:   req.RequestedBy,
+			ApprovedBy:    req.ApprovedBy,
+			AdjustedAt:    time.Now(),
+		})
+		outboxMsg, err := adjustedEvent.ToOutbox(ctx, s.cfg.Kafka.Topic.AccountChange, strconv.FormatInt(req.UserID, 10))
+		if err != nil {
+			return fmt.Errorf("构造消息失败: %w", err)
+		}
+		if err := s.outboxRepo.Create(ctx, tx, outboxMsg); err != nil {
+			return fmt.Errorf("写入消息失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(context.Cause(ctx), lock.ErrLockLost) {
+			return nil, fmt.Errorf("系统繁忙，请稍后重试: %w", lock.ErrLockLost)
+		}
+		return nil, err
+	}
+
+	accountLog.InfoContext(ctx, "调账成功", "adjustment_no", req.AdjustmentNo, "user_id", req.UserID,
+		"amount", req.Amount, "requested_by", req.RequestedBy, "approved_by", req.ApprovedBy)
+	return transaction, nil
+}
+
+// SetFrozen 冻结/解冻账户，冻结后不能支付
+func (s *AccountService) SetFrozen(ctx context.Context, userID int64, frozen bool) error {
+	status := model.AccountStatusActive
+	if frozen {
+		status = model.AccountStatusFrozen
+	}
+	return s.accountRepo.UpdateStatus(ctx, userID, status)
 }
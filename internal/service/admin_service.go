@@ -0,0 +1,424 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"paysystem/internal/config"
+	"paysystem/internal/infrastructure/lock"
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/model"
+	"paysystem/internal/repository"
+	"paysystem/pkg/errs"
+	"paysystem/pkg/idgen"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// ============================================================================
+// 管理后台服务
+// ============================================================================
+//
+// 所有写操作统一走 submit：
+//
+//   金额 <= 审批阈值：直接执行，写审计日志（SUCCESS / FAILED）
+//   金额 >  审批阈值：生成审批单，写审计日志（PENDING_APPROVAL）
+//   人工调账：不论金额大小都生成审批单（maker-checker）
+//                     -> 另一位有权限的管理员审批通过后执行，写审计日志
+//
+// 审批人不能是发起人，且自己也必须拥有该操作的权限。
+// 审批单号同时作为退款、调账的幂等ID，重复执行不会重复入账。
+// 因此执行失败（FAILED）的审批单可以再次审批通过，重新执行；执行中进程崩溃（停在 APPROVED）的审批单
+// 超过执行超时后才允许重新执行，避免和仍在执行的那一次同时执行。审批按读到的状态和更新时间比较交换，
+// 并发的两次审批只有一次能通过。
+//
+// 【用户锁】涉及用户资金的操作（退款、调账、冻结）执行期间持有该用户的管理后台锁，
+// 同一用户的人工操作串行执行。锁是可重入的，持有者为本次执行的标识（审批单号 + 时间，通过 ctx 传递）：
+// 外层锁住整个审批单的执行，每个操作内部再按用户加锁，组合操作（为一个用户退多笔订单并调账）
+// 嵌套调用时不会自己等自己。
+//
+// ============================================================================
+
+var adminLog = logging.For("AdminService")
+
+// AdminOperator 当前操作的管理员
+type AdminOperator struct {
+	Username string
+	Role     string
+	ClientIP string
+}
+
+type AdminRefundPayload struct {
+	OrderNo string `json:"order_no"`
+	Reason  string `json:"reason"`
+}
+
+type AdminAdjustPayload struct {
+	UserID int64  `json:"user_id"`
+	Amount int64  `json:"amount"` // 正数加款，负数扣款
+	Reason string `json:"reason"`
+}
+
+type AdminFreezePayload struct {
+	UserID int64  `json:"user_id"`
+	Frozen bool   `json:"frozen"`
+	Reason string `json:"reason"`
+}
+
+type AdminOutboxReplayPayload struct {
+	IDs    []int64 `json:"ids"`
+	Reason string  `json:"reason"`
+}
+
+// AdminActionResult 写操作结果：已执行，或等待审批
+type AdminActionResult struct {
+	Status     string      `json:"status"` // EXECUTED | PENDING
+	ApprovalNo string      `json:"approval_no,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+type AdminService struct {
+	db             *gorm.DB
+	rdb            *redis.Client
+	cfg            *config.Config
+	adminRepo      *repository.AdminRepository
+	orderRepo      *repository.OrderRepository
+	outboxRepo     *repository.OutboxRepository
+	accountService *AccountService
+	refundService  *RefundService
+}
+
+func NewAdminService(db *gorm.DB, rdb *redis.Client, locker lock.Locker, cfg *config.Config) *AdminService {
+	return &AdminService{
+		db:             db,
+		rdb:            rdb,
+		cfg:            cfg,
+		adminRepo:      repository.NewAdminRepository(db),
+		orderRepo:      repository.NewOrderRepository(db),
+		outboxRepo:     repository.NewOutboxRepository(db),
+		accountService: NewAccountService(db, locker, cfg),
+		refundService:  NewRefundService(db, locker, cfg),
+	}
+}
+
+// GetAdminUser 查询管理员
+func (s *AdminService) GetAdminUser(ctx context.Context, username string) (*model.AdminUser, error) {
+	return s.adminRepo.GetUserByUsername(ctx, username)
+}
+
+func (s *AdminService) SearchOrders(ctx context.Context, filter *repository.OrderSearchFilter, page, pageSize int) ([]*model.PayOrder, int64, error) {
+	return s.orderRepo.Search(ctx, filter, page, pageSize)
+}
+
+func (s *AdminService) ListApprovals(ctx context.Context, status string, page, pageSize int) ([]*model.AdminApproval, int64, error) {
+	return s.adminRepo.ListApprovals(ctx, status, page, pageSize)
+}
+
+func (s *AdminService) ListAuditLogs(ctx context.Context, filter *repository.AuditLogFilter, page, pageSize int) ([]*model.AdminAuditLog, int64, error) {
+	return s.adminRepo.ListAuditLogs(ctx, filter, page, pageSize)
+}
+
+// Refund 人工退款，金额为订单金额
+func (s *AdminService) Refund(ctx context.Context, op *AdminOperator, payload *AdminRefundPayload) (*AdminActionResult, error) {
+	order, err := s.orderRepo.GetByOrderNo(ctx, payload.OrderNo)
+	if err != nil {
+		return nil, err
+	}
+	return s.submit(ctx, op, model.AdminPermOrderRefund, payload.OrderNo, order.Amount, payload.Reason, payload)
+}
+
+// AdjustBalance 发起人工调账申请，审批通过后以 ADJUSTMENT 流水入账
+func (s *AdminService) AdjustBalance(ctx context.Context, op *AdminOperator, payload *AdminAdjustPayload) (*AdminActionResult, error) {
+	if payload.Amount == 0 {
+		return nil, errs.ErrInvalidParam.WithDetail("amount")
+	}
+	amount := payload.Amount
+	if amount < 0 {
+		amount = -amount
+	}
+	return s.submit(ctx, op, model.AdminPermAccountAdjust, strconv.FormatInt(payload.UserID, 10), amount, payload.Reason, payload)
+}
+
+// FreezeAccount 冻结/解冻账户
+func (s *AdminService) FreezeAccount(ctx context.Context, op *AdminOperator, payload *AdminFreezePayload) (*AdminActionResult, error) {
+	return s.submit(ctx, op, model.AdminPermAccountFreeze, strconv.FormatInt(payload.UserID, 10), 0, payload.Reason, payload)
+}
+
+// ReplayOutbox 重新投递 outbox 消息
+func (s *AdminService) ReplayOutbox(ctx context.Context, op *AdminOperator, payload *AdminOutboxReplayPayload) (*AdminActionResult, error) {
+	if len(payload.IDs) == 0 {
+		return nil, errs.ErrInvalidParam.WithDetail("ids")
+	}
+	return s.submit(ctx, op, model.AdminPermOutboxReplay, fmt.Sprintf("%d条消息", len(payload.IDs)), 0, payload.Reason, payload)
+}
+
+// submit 执行写操作，金额超过阈值时生成审批单
+func (s *AdminService) submit(ctx context.Context, op *AdminOperator, action, target string, amount int64, reason string, payload interface{}) (*AdminActionResult, error) {
+	if !model.AdminHasPermission(op.Role, action) {
+		return nil, ErrAdminPermissionDenied
+	}
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	approval := &model.AdminApproval{
+		ApprovalNo:  idgen.GenerateApprovalNo(),
+		Action:      action,
+		Target:      target,
+		Amount:      amount,
+		Payload:     string(data),
+		Reason:      reason,
+		Status:      model.ApprovalStatusPending,
+		RequestedBy: op.Username,
+	}
+
+	if requiresApproval(action, amount, s.cfg.Admin.ApprovalThreshold) {
+		if err := s.adminRepo.CreateApproval(ctx, nil, approval); err != nil {
+			return nil, fmt.Errorf("创建审批单失败: %w", err)
+		}
+		s.audit(ctx, op, approval, model.AuditResultPendingApproval, "金额超过审批阈值，等待审批")
+		return &AdminActionResult{Status: model.ApprovalStatusPending, ApprovalNo: approval.ApprovalNo}, nil
+	}
+
+	// 不需要审批的操作也生成单号，作为退款的幂等ID
+	result, err := s.executeLocked(ctx, approval)
+	if err != nil {
+		s.audit(ctx, op, approval, model.AuditResultFailed, err.Error())
+		return nil, err
+	}
+	s.audit(ctx, op, approval, model.AuditResultSuccess, "")
+	return &AdminActionResult{Status: model.ApprovalStatusExecuted, Data: result}, nil
+}
+
+// requiresApproval 是否需要审批：调账一律需要（maker-checker），其他操作金额超过阈值时需要
+func requiresApproval(action string, amount, threshold int64) bool {
+	return action == model.AdminPermAccountAdjust || amount > threshold
+}
+
+const defaultExecuteTimeout = 10 * time.Minute
+
+// approvable 是否可以审批通过（执行）：待审批、执行失败，或执行中超过执行超时（视为执行中断）
+func (s *AdminService) approvable(approval *model.AdminApproval) bool {
+	switch approval.Status {
+	case model.ApprovalStatusPending, model.ApprovalStatusFailed:
+		return true
+	case model.ApprovalStatusApproved:
+		timeout := time.Duration(s.cfg.Admin.ExecuteTimeoutSeconds) * time.Second
+		if timeout <= 0 {
+			timeout = defaultExecuteTimeout
+		}
+		return time.Since(approval.UpdatedAt) >= timeout
+	}
+	return false
+}
+
+// Approve 审批通过并执行
+func (s *AdminService) Approve(ctx context.Context, op *AdminOperator, approvalNo, note string) (*AdminActionResult, error) {
+	approval, err := s.checkReviewer(ctx, op, approvalNo, s.approvable)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.adminRepo.Review(ctx, approval, model.ApprovalStatusApproved, op.Username, note); err != nil {
+		return nil, err
+	}
+	if approval.Status != model.ApprovalStatusPending {
+		adminLog.WarnContext(ctx, "重新执行审批单", "approval_no", approvalNo, "status", approval.Status, "reviewer", op.Username)
+	}
+	approval.ReviewedBy = op.Username
+
+	result, execErr := s.executeLocked(ctx, approval)
+	status, message := model.ApprovalStatusExecuted, ""
+	if execErr != nil {
+		status, message = model.ApprovalStatusFailed, execErr.Error()
+	} else if data, err := json.Marshal(result); err == nil {
+		message = string(data)
+	}
+	if err := s.adminRepo.FinishApproval(ctx, approvalNo, status, message); err != nil {
+		adminLog.ErrorContext(ctx, "更新审批单结果失败", "approval_no", approvalNo, "err", err)
+	}
+
+	if execErr != nil {
+		s.audit(ctx, op, approval, model.AuditResultFailed, "审批通过，执行失败: "+execErr.Error())
+		return nil, execErr
+	}
+	s.audit(ctx, op, approval, model.AuditResultSuccess, "审批通过")
+	return &AdminActionResult{Status: model.ApprovalStatusExecuted, ApprovalNo: approvalNo, Data: result}, nil
+}
+
+// Reject 驳回
+func (s *AdminService) Reject(ctx context.Context, op *AdminOperator, approvalNo, note string) error {
+	approval, err := s.checkReviewer(ctx, op, approvalNo, func(approval *model.AdminApproval) bool {
+		return approval.Status == model.ApprovalStatusPending
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.adminRepo.Review(ctx, approval, model.ApprovalStatusRejected, op.Username, note); err != nil {
+		return err
+	}
+	s.audit(ctx, op, approval, model.AuditResultRejected, note)
+	return nil
+}
+
+func (s *AdminService) checkReviewer(ctx context.Context, op *AdminOperator, approvalNo string, allowed func(*model.AdminApproval) bool) (*model.AdminApproval, error) {
+	approval, err := s.adminRepo.GetApproval(ctx, approvalNo)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed(approval) {
+		return nil, repository.ErrApprovalStatusConflict
+	}
+	if approval.RequestedBy == op.Username {
+		return nil, ErrSelfApproval
+	}
+	if !model.AdminHasPermission(op.Role, model.AdminPermApprovalReview) || !model.AdminHasPermission(op.Role, approval.Action) {
+		return nil, ErrAdminPermissionDenied
+	}
+	return approval, nil
+}
+
+// executeLocked 锁住操作的用户后执行审批单
+// 持有者为 审批单号:本次执行，执行中断后重新执行时不会重入仍未释放的上一次执行的锁
+func (s *AdminService) executeLocked(ctx context.Context, approval *model.AdminApproval) (interface{}, error) {
+	ctx = lock.WithOwner(ctx, fmt.Sprintf("%s:%d", approval.ApprovalNo, time.Now().UnixNano()))
+
+	userID, err := s.approvalUserID(ctx, approval)
+	if err != nil {
+		return nil, err
+	}
+	if userID == 0 {
+		return s.execute(ctx, approval)
+	}
+	var result interface{}
+	err = s.withUserLock(ctx, userID, func(ctx context.Context) error {
+		result, err = s.execute(ctx, approval)
+		return err
+	})
+	return result, err
+}
+
+// approvalUserID 审批单操作的用户，不涉及用户资金的操作返回 0
+func (s *AdminService) approvalUserID(ctx context.Context, approval *model.AdminApproval) (int64, error) {
+	switch approval.Action {
+	case model.AdminPermOrderRefund:
+		var payload AdminRefundPayload
+		if err := json.Unmarshal([]byte(approval.Payload), &payload); err != nil {
+			return 0, err
+		}
+		order, err := s.orderRepo.GetByOrderNo(ctx, payload.OrderNo)
+		if err != nil {
+			return 0, err
+		}
+		return order.UserID, nil
+	case model.AdminPermAccountAdjust, model.AdminPermAccountFreeze:
+		return strconv.ParseInt(approval.Target, 10, 64)
+	}
+	return 0, nil
+}
+
+// withUserLock 持有用户的管理后台锁执行 fn，可重入：持有者取 ctx 中的本次执行标识
+func (s *AdminService) withUserLock(ctx context.Context, userID int64, fn func(ctx context.Context) error) error {
+	owner, _ := lock.OwnerFromContext(ctx)
+	userLock := lock.NewReentrantLock(s.rdb, lock.AdminUserLockKey(userID), owner, 60*time.Second)
+	if _, err := userLock.Lock(ctx, 100*time.Millisecond, 50); err != nil {
+		return fmt.Errorf("用户正在被其他管理操作处理，请稍后重试: %w", err)
+	}
+	defer userLock.Unlock(context.WithoutCancel(ctx))
+	return fn(userLock.StartWatchdog(ctx))
+}
+
+// execute 执行审批单上的操作，涉及用户资金的操作在用户锁内执行
+func (s *AdminService) execute(ctx context.Context, approval *model.AdminApproval) (interface{}, error) {
+	switch approval.Action {
+	case model.AdminPermOrderRefund:
+		var payload AdminRefundPayload
+		if err := json.Unmarshal([]byte(approval.Payload), &payload); err != nil {
+			return nil, err
+		}
+		order, err := s.orderRepo.GetByOrderNo(ctx, payload.OrderNo)
+		if err != nil {
+			return nil, err
+		}
+		var result *RefundResponse
+		err = s.withUserLock(ctx, order.UserID, func(ctx context.Context) error {
+			result, err = s.refundService.Refund(ctx, &RefundRequest{
+				RequestID: approval.ApprovalNo,
+				OrderNo:   payload.OrderNo,
+				Reason:    payload.Reason,
+			})
+			return err
+		})
+		return result, err
+
+	case model.AdminPermAccountAdjust:
+		var payload AdminAdjustPayload
+		if err := json.Unmarshal([]byte(approval.Payload), &payload); err != nil {
+			return nil, err
+		}
+		var result *model.AccountTransaction
+		err := s.withUserLock(ctx, payload.UserID, func(ctx context.Context) (err error) {
+			result, err = s.accountService.AdjustBalance(ctx, &AdjustBalanceRequest{
+				AdjustmentNo: approval.ApprovalNo,
+				UserID:       payload.UserID,
+				Amount:       payload.Amount,
+				Reason:       payload.Reason,
+				RequestedBy:  approval.RequestedBy,
+				ApprovedBy:   approval.ReviewedBy,
+			})
+			return err
+		})
+		return result, err
+
+	case model.AdminPermAccountFreeze:
+		var payload AdminFreezePayload
+		if err := json.Unmarshal([]byte(approval.Payload), &payload); err != nil {
+			return nil, err
+		}
+		return nil, s.withUserLock(ctx, payload.UserID, func(ctx context.Context) error {
+			return s.accountService.SetFrozen(ctx, payload.UserID, payload.Frozen)
+		})
+
+	case model.AdminPermOutboxReplay:
+		var payload AdminOutboxReplayPayload
+		if err := json.Unmarshal([]byte(approval.Payload), &payload); err != nil {
+			return nil, err
+		}
+		count, err := s.outboxRepo.Replay(ctx, payload.IDs)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]int64{"replayed": count}, nil
+	}
+	return nil, fmt.Errorf("未知的操作: %s", approval.Action)
+}
+
+// audit 写审计日志，失败只打印日志，不影响已完成的操作
+func (s *AdminService) audit(ctx context.Context, op *AdminOperator, approval *model.AdminApproval, result, message string) {
+	if runes := []rune(message); len(runes) > 512 {
+		message = string(runes[:512])
+	}
+	entry := &model.AdminAuditLog{
+		Operator:   op.Username,
+		Role:       op.Role,
+		Action:     approval.Action,
+		Target:     approval.Target,
+		ApprovalNo: approval.ApprovalNo,
+		Payload:    approval.Payload,
+		Result:     result,
+		Message:    message,
+		ClientIP:   op.ClientIP,
+	}
+	if err := s.adminRepo.CreateAuditLog(context.WithoutCancel(ctx), entry); err != nil {
+		adminLog.ErrorContext(ctx, "写审计日志失败", "operator", op.Username, "action", approval.Action, "target", approval.Target, "err", err)
+	}
+}
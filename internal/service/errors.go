@@ -0,0 +1,26 @@
+package service
+
+import (
+	"net/http"
+
+	"paysystem/pkg/errs"
+	"paysystem/pkg/response"
+)
+
+// 服务层业务错误（仓储层的错误见 repository 包）
+var (
+	ErrInvalidAmount  = errs.New(response.CodeParamError, "account.invalid_amount", http.StatusBadRequest, false, "金额必须大于0")
+	ErrAccountFrozen  = errs.New(response.CodeAccountFrozen, "account.frozen", http.StatusForbidden, false, "账户已冻结")
+	ErrReasonRequired = errs.New(response.CodeParamError, "admin.reason_required", http.StatusBadRequest, false, "操作原因不能为空")
+
+	ErrAdminPermissionDenied = errs.New(response.CodeForbidden, "admin.permission_denied", http.StatusForbidden, false, "无权执行该操作")
+	ErrSelfApproval          = errs.New(response.CodeForbidden, "admin.self_approval", http.StatusForbidden, false, "不能审批自己发起的操作")
+)
+
+// 结果提示的消息键（见 pkg/i18n 语言包），由处理器按请求语言翻译
+const (
+	MsgPaySuccess            = "pay.success"
+	MsgPayOrderExists        = "pay.order_exists"
+	MsgRefundSuccess         = "refund.success"
+	MsgRefundAlreadyRefunded = "refund.already_refunded"
+)
@@ -2,27 +2,38 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"paysystem/internal/config"
+	"paysystem/internal/event"
+	"paysystem/internal/infrastructure/cache"
+	"paysystem/internal/infrastructure/logging"
 	"paysystem/internal/model"
 	"paysystem/internal/repository"
 	"paysystem/pkg/idgen"
 
+	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 )
 
+var orderLog = logging.For("OrderService")
+
 type OrderService struct {
-	orderRepo *repository.OrderRepository
-	db        *gorm.DB
-	cfg       *config.Config
+	orderRepo   *repository.OrderRepository
+	outboxRepo  *repository.OutboxRepository
+	expireQueue *cache.DelayQueue
+	db          *gorm.DB
+	cfg         *config.Config
 }
 
-func NewOrderService(db *gorm.DB, cfg *config.Config) *OrderService {
+func NewOrderService(db *gorm.DB, redisClient *redis.Client, cfg *config.Config) *OrderService {
 	return &OrderService{
-		orderRepo: repository.NewOrderRepository(db),
-		db:        db,
-		cfg:       cfg,
+		orderRepo:   repository.NewOrderRepository(db),
+		outboxRepo:  repository.NewOutboxRepository(db),
+		expireQueue: cache.NewOrderExpireQueue(redisClient),
+		db:          db,
+		cfg:         cfg,
 	}
 }
 
@@ -62,6 +73,11 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 		return nil, err
 	}
 
+	// 加入超时队列失败不影响下单，超时任务的兜底扫描会关闭该订单
+	if err := s.expireQueue.Add(ctx, orderNo, expiredAt); err != nil {
+		orderLog.WarnContext(ctx, "订单加入超时队列失败", "order_no", orderNo, "err", err)
+	}
+
 	return order, nil
 }
 
@@ -73,13 +89,99 @@ func (s *OrderService) GetOrderByRequestID(ctx context.Context, requestID string
 	return s.orderRepo.GetByRequestID(ctx, requestID)
 }
 
+// CancelOrder 取消订单，只有未支付（CREATED）的订单可以取消
+// 状态按 CREATED 条件更新，并发支付已经把订单改为支付中时取消失败，不会发出错误的取消事件
 func (s *OrderService) CancelOrder(ctx context.Context, orderNo string) error {
 	order, err := s.orderRepo.GetByOrderNo(ctx, orderNo)
 	if err != nil {
 		return err
 	}
+	if order.Status != model.OrderStatusCreated {
+		return repository.ErrOrderStatusInvalid.WithDetail("status=%s", order.Status)
+	}
+
+	cancelledEvent := event.NewOrderCancelled(&event.OrderCancelledData{
+		OrderNo:     order.OrderNo,
+		UserID:      order.UserID,
+		Amount:      order.Amount,
+		ProductType: order.ProductType,
+		ProductID:   order.ProductID,
+		Status:      model.OrderStatusCancelled,
+		CancelledAt: time.Now(),
+	})
+	err = s.updateStatusWithEvent(ctx, order, model.OrderStatusCancelled, cancelledEvent, s.cfg.Kafka.Topic.PayResult)
+	if err != nil {
+		return err
+	}
+
+	if err := s.expireQueue.Remove(ctx, orderNo); err != nil {
+		orderLog.WarnContext(ctx, "订单移出超时队列失败", "order_no", orderNo, "err", err)
+	}
+	return nil
+}
+
+// CloseExpiredOrder 关闭超时订单
+func (s *OrderService) CloseExpiredOrder(ctx context.Context, order *model.PayOrder) error {
+	closedEvent := event.NewOrderClosed(&event.OrderClosedData{
+		OrderNo:     order.OrderNo,
+		UserID:      order.UserID,
+		Amount:      order.Amount,
+		ProductType: order.ProductType,
+		ProductID:   order.ProductID,
+		Status:      model.OrderStatusClosed,
+		ExpiredAt:   order.ExpiredAt,
+		ClosedAt:    time.Now(),
+	})
+	return s.updateStatusWithEvent(ctx, order, model.OrderStatusClosed, closedEvent, s.cfg.Kafka.Topic.OrderTimeout)
+}
+
+// CompletePayingOrder 补偿：已扣款但状态停留在 PAYING 的订单更新为 PAID
+func (s *OrderService) CompletePayingOrder(ctx context.Context, order *model.PayOrder) error {
+	paidEvent := event.NewOrderPaid(&event.OrderPaidData{
+		OrderNo:     order.OrderNo,
+		UserID:      order.UserID,
+		Amount:      order.Amount,
+		ProductType: order.ProductType,
+		ProductID:   order.ProductID,
+		Status:      model.OrderStatusPaid,
+		PaidAt:      time.Now(),
+	})
+	return s.updateStatusWithEvent(ctx, order, model.OrderStatusPaid, paidEvent, s.cfg.Kafka.Topic.PayResult)
+}
+
+// FailPayingOrder 补偿：无扣款流水且已超时的 PAYING 订单标记为 FAILED
+func (s *OrderService) FailPayingOrder(ctx context.Context, order *model.PayOrder, reason string) error {
+	failedEvent := event.NewOrderFailed(&event.OrderFailedData{
+		OrderNo:     order.OrderNo,
+		UserID:      order.UserID,
+		Amount:      order.Amount,
+		ProductType: order.ProductType,
+		ProductID:   order.ProductID,
+		Status:      model.OrderStatusFailed,
+		Reason:      reason,
+		FailedAt:    time.Now(),
+	})
+	return s.updateStatusWithEvent(ctx, order, model.OrderStatusFailed, failedEvent, s.cfg.Kafka.Topic.PayResult)
+}
+
+// updateStatusWithEvent 更新订单状态，并在同一事务内写入状态变更事件，
+// 保证"状态变了，业务方一定能收到通知"
+func (s *OrderService) updateStatusWithEvent(ctx context.Context, order *model.PayOrder, toStatus string, evt *event.Envelope, topic string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.orderRepo.UpdateStatus(ctx, tx, order.OrderNo, order.Status, toStatus); err != nil {
+			return err
+		}
+
+		outboxMsg, err := evt.ToOutbox(ctx, topic, order.OrderNo)
+		if err != nil {
+			return fmt.Errorf("构造消息失败: %w", err)
+		}
+		if err := s.outboxRepo.Create(ctx, tx, outboxMsg); err != nil {
+			return fmt.Errorf("写入消息失败: %w", err)
+		}
 
-	return s.orderRepo.UpdateStatus(ctx, nil, orderNo, order.Status, model.OrderStatusCancelled)
+		return nil
+	})
 }
 
 func (s *OrderService) CloseExpiredOrders(ctx context.Context, limit int) (int, error) {
@@ -90,7 +192,7 @@ func (s *OrderService) CloseExpiredOrders(ctx context.Context, limit int) (int,
 
 	closedCount := 0
 	for _, order := range orders {
-		err := s.orderRepo.UpdateStatus(ctx, nil, order.OrderNo, model.OrderStatusCreated, model.OrderStatusClosed)
+		err := s.CloseExpiredOrder(ctx, order)
 		if err == nil {
 			closedCount++
 		}
@@ -2,25 +2,27 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"time"
 
 	"paysystem/internal/config"
+	"paysystem/internal/event"
 	"paysystem/internal/infrastructure/lock"
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/infrastructure/metrics"
 	"paysystem/internal/model"
 	"paysystem/internal/repository"
 	"paysystem/pkg/idgen"
 
-	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 )
 
+var payLog = logging.For("PayService")
+
 type PayService struct {
 	db              *gorm.DB
-	redisClient     *redis.Client
+	locker          lock.Locker
 	cfg             *config.Config
 	orderRepo       *repository.OrderRepository
 	accountRepo     *repository.AccountRepository
@@ -28,10 +30,10 @@ type PayService struct {
 	outboxRepo      *repository.OutboxRepository
 }
 
-func NewPayService(db *gorm.DB, redisClient *redis.Client, cfg *config.Config) *PayService {
+func NewPayService(db *gorm.DB, locker lock.Locker, cfg *config.Config) *PayService {
 	return &PayService{
 		db:              db,
-		redisClient:     redisClient,
+		locker:          locker,
 		cfg:             cfg,
 		orderRepo:       repository.NewOrderRepository(db),
 		accountRepo:     repository.NewAccountRepository(db),
@@ -52,10 +54,12 @@ type PayResponse struct {
 	OrderNo string `json:"order_no"`
 	Status  string `json:"status"`
 	Amount  int64  `json:"amount"`
-	Message string `json:"message,omitempty"`
+	Message string `json:"message,omitempty"` // 消息键，处理器按请求语言翻译后返回
 }
 
-func (s *PayService) Pay(ctx context.Context, req *PayRequest) (*PayResponse, error) {
+func (s *PayService) Pay(ctx context.Context, req *PayRequest) (resp *PayResponse, err error) {
+	defer func() { observePay(req, resp, err) }()
+
 	// 幂等校验
 	existingOrder, err := s.orderRepo.GetByRequestID(ctx, req.RequestID)
 	if err != nil {
@@ -67,18 +71,33 @@ func (s *PayService) Pay(ctx context.Context, req *PayRequest) (*PayResponse, er
 			OrderNo: existingOrder.OrderNo,
 			Status:  existingOrder.Status,
 			Amount:  existingOrder.Amount,
-			Message: "订单已存在",
+			Message: MsgPayOrderExists,
 		}, nil
 	}
 
 	// 获取分布式锁
-	payLock := lock.NewPayLock(s.redisClient, req.UserID, req.RequestID)
-	err = payLock.Lock(ctx, 100*time.Millisecond, 30)
+	// MySQL 行锁实现会在事务内锁住账户行，并以行锁读到的账户数据为准
+	var account *model.Account
+	payLock := s.locker.NewLock(lock.Resource{
+		Key: lock.PayLockKey(req.UserID),
+		RowLock: func(ctx context.Context, tx *gorm.DB) error {
+			lockedAccount, err := s.accountRepo.GetByUserIDForUpdate(ctx, tx, req.UserID)
+			if err != nil {
+				return err
+			}
+			account = lockedAccount
+			return nil
+		},
+	}, req.RequestID, 30*time.Second)
+	fenceToken, err := payLock.Lock(ctx, 100*time.Millisecond, 30)
 	if err != nil {
 		return nil, fmt.Errorf("系统繁忙，请稍后重试: %w", err)
 	}
 	defer payLock.Unlock(ctx)
 
+	// 开启看门狗：事务较慢时自动续期，锁一旦丢失 ctx 会被取消，事务随之回滚
+	ctx = payLock.StartWatchdog(ctx)
+
 	// 获取锁后再次检查幂等
 	existingOrder, err = s.orderRepo.GetByRequestID(ctx, req.RequestID)
 	if err != nil {
@@ -89,22 +108,27 @@ func (s *PayService) Pay(ctx context.Context, req *PayRequest) (*PayResponse, er
 			OrderNo: existingOrder.OrderNo,
 			Status:  existingOrder.Status,
 			Amount:  existingOrder.Amount,
-			Message: "订单已存在",
+			Message: MsgPayOrderExists,
 		}, nil
 	}
 
 	// 检查账户余额
-	account, err := s.accountRepo.GetOrCreate(ctx, req.UserID)
+	account, err = s.accountRepo.GetOrCreate(ctx, req.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("获取账户信息失败: %w", err)
 	}
 
+	if account.Status == model.AccountStatusFrozen {
+		return nil, ErrAccountFrozen
+	}
+
 	if account.Balance < req.Amount {
-		return nil, errors.New("余额不足")
+		return nil, repository.ErrBalanceNotEnough
 	}
 
 	// 创建订单
 	orderNo := idgen.GenerateOrderNo()
+	ctx = logging.WithFields(ctx, "order_no", orderNo)
 	expiredAt := time.Now().Add(time.Duration(s.cfg.Business.OrderTimeoutMinutes) * time.Minute)
 
 	order := &model.PayOrder{
@@ -120,6 +144,10 @@ func (s *PayService) Pay(ctx context.Context, req *PayRequest) (*PayResponse, er
 
 	// 执行支付事务
 	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := payLock.LockInTx(ctx, tx); err != nil {
+			return fmt.Errorf("锁定账户失败: %w", err)
+		}
+
 		if err := s.orderRepo.Create(ctx, tx, order); err != nil {
 			return fmt.Errorf("创建订单失败: %w", err)
 		}
@@ -128,13 +156,8 @@ func (s *PayService) Pay(ctx context.Context, req *PayRequest) (*PayResponse, er
 			return fmt.Errorf("更新订单状态失败: %w", err)
 		}
 
-		if err := s.accountRepo.Deduct(ctx, tx, req.UserID, req.Amount, account.Version); err != nil {
-			if errors.Is(err, repository.ErrBalanceNotEnough) {
-				return errors.New("余额不足")
-			}
-			if errors.Is(err, repository.ErrOptimisticLock) {
-				return errors.New("系统繁忙，请重试")
-			}
+		// 余额不足、乐观锁冲突、fencing token 过期都是带类型的错误，由处理器转换成对应的响应
+		if err := s.accountRepo.Deduct(ctx, tx, req.UserID, req.Amount, account.Version, fenceToken); err != nil {
 			return fmt.Errorf("扣款失败: %w", err)
 		}
 
@@ -159,22 +182,18 @@ func (s *PayService) Pay(ctx context.Context, req *PayRequest) (*PayResponse, er
 			return fmt.Errorf("更新订单状态失败: %w", err)
 		}
 
-		msgPayload := map[string]interface{}{
-			"order_no":     orderNo,
-			"user_id":      req.UserID,
-			"amount":       req.Amount,
-			"product_type": req.ProductType,
-			"product_id":   req.ProductID,
-			"status":       model.OrderStatusPaid,
-			"paid_at":      now.Format(time.RFC3339),
-		}
-		payloadBytes, _ := json.Marshal(msgPayload)
-
-		outboxMsg := &model.OutboxMessage{
-			MessageKey: orderNo,
-			Topic:      s.cfg.Kafka.Topic.PayResult,
-			Payload:    string(payloadBytes),
-			Status:     model.OutboxStatusPending,
+		paidEvent := event.NewOrderPaid(&event.OrderPaidData{
+			OrderNo:     orderNo,
+			UserID:      req.UserID,
+			Amount:      req.Amount,
+			ProductType: req.ProductType,
+			ProductID:   req.ProductID,
+			Status:      model.OrderStatusPaid,
+			PaidAt:      now,
+		})
+		outboxMsg, err := paidEvent.ToOutbox(ctx, s.cfg.Kafka.Topic.PayResult, orderNo)
+		if err != nil {
+			return fmt.Errorf("构造消息失败: %w", err)
 		}
 		if err := s.outboxRepo.Create(ctx, tx, outboxMsg); err != nil {
 			return fmt.Errorf("写入消息失败: %w", err)
@@ -184,25 +203,49 @@ func (s *PayService) Pay(ctx context.Context, req *PayRequest) (*PayResponse, er
 	})
 
 	if err != nil {
+		if errors.Is(context.Cause(ctx), lock.ErrLockLost) {
+			return nil, fmt.Errorf("系统繁忙，请稍后重试: %w", lock.ErrLockLost)
+		}
 		return nil, err
 	}
 
-	log.Printf("支付成功: orderNo=%s, userID=%d, amount=%d", orderNo, req.UserID, req.Amount)
+	payLog.InfoContext(ctx, "支付成功", "user_id", req.UserID, "amount", req.Amount)
 
 	return &PayResponse{
 		OrderNo: orderNo,
 		Status:  model.OrderStatusPaid,
 		Amount:  req.Amount,
-		Message: "支付成功",
+		Message: MsgPaySuccess,
 	}, nil
 }
 
+func observePay(req *PayRequest, resp *PayResponse, err error) {
+	productType := productTypeLabel(req.ProductType)
+	switch {
+	case err != nil:
+		metrics.PayTotal.WithLabelValues(productType, metrics.ResultFailure).Inc()
+		metrics.ObserveFailure("pay", err)
+	case resp.Message == MsgPayOrderExists:
+		metrics.PayTotal.WithLabelValues(productType, metrics.ResultDuplicate).Inc()
+	default:
+		metrics.PayTotal.WithLabelValues(productType, metrics.ResultSuccess).Inc()
+		metrics.PayAmount.WithLabelValues(productType).Add(float64(req.Amount))
+	}
+}
+
+// productTypeLabel 产品类型作为指标标签，未知取值（来自客户端或上游命令）归为 other，避免标签基数失控
+func productTypeLabel(productType string) string {
+	switch productType {
+	case model.ProductTypeCoinVideo, model.ProductTypeCoinProduct:
+		return productType
+	default:
+		return "other"
+	}
+}
+
 func (s *PayService) QueryPayResult(ctx context.Context, orderNo string) (*PayResponse, error) {
 	order, err := s.orderRepo.GetByOrderNo(ctx, orderNo)
 	if err != nil {
-		if errors.Is(err, repository.ErrOrderNotFound) {
-			return nil, errors.New("订单不存在")
-		}
 		return nil, err
 	}
 
@@ -219,7 +262,7 @@ func (s *PayService) QueryPayResultByRequestID(ctx context.Context, requestID st
 		return nil, err
 	}
 	if order == nil {
-		return nil, errors.New("订单不存在")
+		return nil, repository.ErrOrderNotFound
 	}
 
 	return &PayResponse{
@@ -2,25 +2,27 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"time"
 
 	"paysystem/internal/config"
+	"paysystem/internal/event"
 	"paysystem/internal/infrastructure/lock"
+	"paysystem/internal/infrastructure/logging"
+	"paysystem/internal/infrastructure/metrics"
 	"paysystem/internal/model"
 	"paysystem/internal/repository"
 	"paysystem/pkg/idgen"
 
-	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 )
 
+var refundLog = logging.For("RefundService")
+
 type RefundService struct {
 	db              *gorm.DB
-	redisClient     *redis.Client
+	locker          lock.Locker
 	cfg             *config.Config
 	orderRepo       *repository.OrderRepository
 	accountRepo     *repository.AccountRepository
@@ -28,10 +30,10 @@ type RefundService struct {
 	outboxRepo      *repository.OutboxRepository
 }
 
-func NewRefundService(db *gorm.DB, redisClient *redis.Client, cfg *config.Config) *RefundService {
+func NewRefundService(db *gorm.DB, locker lock.Locker, cfg *config.Config) *RefundService {
 	return &RefundService{
 		db:              db,
-		redisClient:     redisClient,
+		locker:          locker,
 		cfg:             cfg,
 		orderRepo:       repository.NewOrderRepository(db),
 		accountRepo:     repository.NewAccountRepository(db),
@@ -51,47 +53,51 @@ type RefundResponse struct {
 	OrderNo  string `json:"order_no"`
 	Amount   int64  `json:"amount"`
 	Status   string `json:"status"`
-	Message  string `json:"message,omitempty"`
+	Message  string `json:"message,omitempty"` // 消息键，处理器按请求语言翻译后返回
 }
 
-func (s *RefundService) Refund(ctx context.Context, req *RefundRequest) (*RefundResponse, error) {
+func (s *RefundService) Refund(ctx context.Context, req *RefundRequest) (resp *RefundResponse, err error) {
+	defer func() { observeRefund(resp, err) }()
+
 	order, err := s.orderRepo.GetByOrderNo(ctx, req.OrderNo)
 	if err != nil {
-		if errors.Is(err, repository.ErrOrderNotFound) {
-			return nil, errors.New("订单不存在")
-		}
 		return nil, fmt.Errorf("查询订单失败: %w", err)
 	}
 
-	if order.Status != model.OrderStatusPaid {
-		return nil, fmt.Errorf("订单状态不允许退款，当前状态: %s", order.Status)
-	}
-
-	existingTrans, err := s.transactionRepo.GetByUserIDAndOrderNo(ctx, order.UserID, req.OrderNo)
+	// 先查退款流水再校验状态：已退款的订单状态是 REFUNDED，重复投递的退款命令、
+	// 客户端重试都应当返回已退款，而不是订单状态不正确（不可重试，会进入死信）
+	existingTrans, err := s.transactionRepo.GetByOrderNoAndType(ctx, nil, order.UserID, req.OrderNo, model.TransactionTypeRefund)
 	if err != nil {
 		return nil, fmt.Errorf("查询流水失败: %w", err)
 	}
-	if existingTrans != nil && existingTrans.Type == model.TransactionTypeRefund {
+	if existingTrans != nil {
 		return &RefundResponse{
 			OrderNo: order.OrderNo,
 			Amount:  order.Amount,
 			Status:  model.OrderStatusRefunded,
-			Message: "已退款，请勿重复操作",
+			Message: MsgRefundAlreadyRefunded,
 		}, nil
 	}
 
-	refundLock := lock.NewDistributedLock(
-		s.redisClient,
-		fmt.Sprintf("refund:lock:order:%s", req.OrderNo),
-		req.RequestID,
-		30*time.Second,
-	)
-	err = refundLock.Lock(ctx, 100*time.Millisecond, 30)
+	if order.Status != model.OrderStatusPaid {
+		return nil, repository.ErrOrderStatusInvalid.WithDetail("status=%s", order.Status)
+	}
+
+	refundLock := s.locker.NewLock(lock.Resource{
+		Key: lock.RefundLockKey(req.OrderNo),
+		RowLock: func(ctx context.Context, tx *gorm.DB) error {
+			_, err := s.orderRepo.GetByOrderNoForUpdate(ctx, tx, req.OrderNo)
+			return err
+		},
+	}, req.RequestID, 30*time.Second)
+	_, err = refundLock.Lock(ctx, 100*time.Millisecond, 30)
 	if err != nil {
 		return nil, fmt.Errorf("系统繁忙，请稍后重试: %w", err)
 	}
 	defer refundLock.Unlock(ctx)
 
+	ctx = refundLock.StartWatchdog(ctx)
+
 	order, err = s.orderRepo.GetByOrderNo(ctx, req.OrderNo)
 	if err != nil {
 		return nil, err
@@ -102,15 +108,20 @@ func (s *RefundService) Refund(ctx context.Context, req *RefundRequest) (*Refund
 				OrderNo: order.OrderNo,
 				Amount:  order.Amount,
 				Status:  model.OrderStatusRefunded,
-				Message: "已退款，请勿重复操作",
+				Message: MsgRefundAlreadyRefunded,
 			}, nil
 		}
-		return nil, fmt.Errorf("订单状态不允许退款，当前状态: %s", order.Status)
+		return nil, repository.ErrOrderStatusInvalid.WithDetail("status=%s", order.Status)
 	}
 
 	refundNo := idgen.GenerateRefundNo()
+	ctx = logging.WithFields(ctx, "order_no", req.OrderNo, "refund_no", refundNo)
 
 	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := refundLock.LockInTx(ctx, tx); err != nil {
+			return fmt.Errorf("锁定订单失败: %w", err)
+		}
+
 		if err := s.orderRepo.UpdateStatus(ctx, tx, req.OrderNo, model.OrderStatusPaid, model.OrderStatusRefunding); err != nil {
 			return fmt.Errorf("更新订单状态失败: %w", err)
 		}
@@ -120,7 +131,8 @@ func (s *RefundService) Refund(ctx context.Context, req *RefundRequest) (*Refund
 			return fmt.Errorf("查询账户失败: %w", err)
 		}
 
-		if err := s.accountRepo.Increase(ctx, tx, order.UserID, order.Amount); err != nil {
+		// 退款锁按订单维度，token 与账户上记录的用户锁 token 不可比较，不参与 fencing 校验
+		if err := s.accountRepo.Increase(ctx, tx, order.UserID, order.Amount, 0); err != nil {
 			return fmt.Errorf("退款到账失败: %w", err)
 		}
 
@@ -142,22 +154,18 @@ func (s *RefundService) Refund(ctx context.Context, req *RefundRequest) (*Refund
 			return fmt.Errorf("更新订单状态失败: %w", err)
 		}
 
-		msgPayload := map[string]interface{}{
-			"refund_no":   refundNo,
-			"order_no":    req.OrderNo,
-			"user_id":     order.UserID,
-			"amount":      order.Amount,
-			"status":      model.OrderStatusRefunded,
-			"reason":      req.Reason,
-			"refunded_at": time.Now().Format(time.RFC3339),
-		}
-		payloadBytes, _ := json.Marshal(msgPayload)
-
-		outboxMsg := &model.OutboxMessage{
-			MessageKey: refundNo,
-			Topic:      s.cfg.Kafka.Topic.PayResult,
-			Payload:    string(payloadBytes),
-			Status:     model.OutboxStatusPending,
+		refundedEvent := event.NewOrderRefunded(&event.OrderRefundedData{
+			RefundNo:   refundNo,
+			OrderNo:    req.OrderNo,
+			UserID:     order.UserID,
+			Amount:     order.Amount,
+			Status:     model.OrderStatusRefunded,
+			Reason:     req.Reason,
+			RefundedAt: time.Now(),
+		})
+		outboxMsg, err := refundedEvent.ToOutbox(ctx, s.cfg.Kafka.Topic.RefundResult, refundNo)
+		if err != nil {
+			return fmt.Errorf("构造消息失败: %w", err)
 		}
 		if err := s.outboxRepo.Create(ctx, tx, outboxMsg); err != nil {
 			return fmt.Errorf("写入消息失败: %w", err)
@@ -167,16 +175,32 @@ func (s *RefundService) Refund(ctx context.Context, req *RefundRequest) (*Refund
 	})
 
 	if err != nil {
+		if errors.Is(context.Cause(ctx), lock.ErrLockLost) {
+			return nil, fmt.Errorf("系统繁忙，请稍后重试: %w", lock.ErrLockLost)
+		}
 		return nil, err
 	}
 
-	log.Printf("退款成功: refundNo=%s, orderNo=%s, amount=%d", refundNo, req.OrderNo, order.Amount)
+	refundLog.InfoContext(ctx, "退款成功", "amount", order.Amount)
 
 	return &RefundResponse{
 		RefundNo: refundNo,
 		OrderNo:  req.OrderNo,
 		Amount:   order.Amount,
 		Status:   model.OrderStatusRefunded,
-		Message:  "退款成功",
+		Message:  MsgRefundSuccess,
 	}, nil
 }
+
+func observeRefund(resp *RefundResponse, err error) {
+	switch {
+	case err != nil:
+		metrics.RefundTotal.WithLabelValues(metrics.ResultFailure).Inc()
+		metrics.ObserveFailure("refund", err)
+	case resp.Message == MsgRefundAlreadyRefunded:
+		metrics.RefundTotal.WithLabelValues(metrics.ResultDuplicate).Inc()
+	default:
+		metrics.RefundTotal.WithLabelValues(metrics.ResultSuccess).Inc()
+		metrics.RefundAmount.Add(float64(resp.Amount))
+	}
+}
@@ -0,0 +1,105 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"paysystem/pkg/response"
+)
+
+// ============================================================================
+// 领域错误
+// ============================================================================
+//
+// 仓储、服务层返回带类型的错误，处理器统一转换成响应：
+//
+//   Code        业务码（见 pkg/response），客户端据此分支，保持稳定
+//   Key         消息键，用于多语言文案
+//   HTTPStatus  HTTP 状态码
+//   Retryable   客户端是否可以原样重试（锁竞争、乐观锁冲突等暂时性错误）；
+//               内部错误不确定操作是否已经生效，不标记为可重试
+//
+// 预定义的错误是哨兵值，用 errors.Is 判断；需要附带上下文时用 WithDetail / Wrap
+// 派生新错误，派生出的错误与原哨兵 errors.Is 仍然成立。
+//
+// ============================================================================
+
+// Error 带类型的业务错误
+type Error struct {
+	Code       int
+	Key        string
+	Message    string // 默认文案
+	HTTPStatus int
+	Retryable  bool
+	Detail     string // 附加说明，如当前订单状态
+	cause      error
+}
+
+// New 定义一个错误
+func New(code int, key string, httpStatus int, retryable bool, message string) *Error {
+	return &Error{
+		Code:       code,
+		Key:        key,
+		Message:    message,
+		HTTPStatus: httpStatus,
+		Retryable:  retryable,
+	}
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.cause != nil {
+		msg += ": " + e.cause.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is 同一种错误（Code 与 Key 相同）即视为相等，派生出的错误与哨兵 errors.Is 成立
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.Key == e.Key
+}
+
+// WithDetail 附加说明
+func (e *Error) WithDetail(format string, args ...interface{}) *Error {
+	clone := *e
+	clone.Detail = fmt.Sprintf(format, args...)
+	return &clone
+}
+
+// Wrap 附带底层原因（只用于日志排查，不返回给客户端）
+func (e *Error) Wrap(cause error) *Error {
+	clone := *e
+	clone.cause = cause
+	return &clone
+}
+
+// 通用错误
+var (
+	ErrInvalidParam = New(response.CodeParamError, "common.invalid_param", http.StatusBadRequest, false, "参数错误")
+	ErrNotFound     = New(response.CodeNotFound, "common.not_found", http.StatusNotFound, false, "资源不存在")
+	ErrInternal     = New(response.CodeServerError, "common.internal", http.StatusInternalServerError, false, "服务器内部错误")
+	ErrSystemBusy   = New(response.CodeSystemBusy, "common.system_busy", http.StatusServiceUnavailable, true, "系统繁忙，请稍后重试")
+	ErrTimeout      = New(response.CodeSystemBusy, "common.timeout", http.StatusGatewayTimeout, true, "请求超时，请稍后重试")
+)
+
+// From 取出错误链上的业务错误，未定义类型的错误视为内部错误
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout.Wrap(err)
+	}
+	return ErrInternal.Wrap(err)
+}
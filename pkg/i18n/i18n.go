@@ -0,0 +1,158 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// 多语言文案
+// ============================================================================
+//
+// 接口返回的文案按消息键从目录中取，业务码保持不变，客户端只依赖业务码做分支。
+//
+//   消息键   错误使用 errs.Error 的 Key（如 account.balance_not_enough），
+//            成功提示等使用各自的键（如 pay.success）
+//   语言包   locales/<语言>.json，键 -> 文案，文案可以带 fmt 占位符
+//   协商     按 Accept-Language 的 q 值选择已支持的语言，都不支持时用默认语言
+//
+// 目录里找不到的键原样返回，新增文案漏翻时不会导致接口报错。
+//
+// ============================================================================
+
+const (
+	ZhCN = "zh-CN"
+	EnUS = "en-US"
+
+	DefaultLocale = ZhCN
+)
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// bundles 语言 -> 消息键 -> 文案
+var bundles = loadBundles()
+
+func loadBundles() map[string]map[string]string {
+	entries, err := localeFS.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("i18n: 读取语言包失败: %v", err))
+	}
+
+	result := make(map[string]map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := localeFS.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("i18n: 读取语言包 %s 失败: %v", entry.Name(), err))
+		}
+		messages := make(map[string]string)
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: 解析语言包 %s 失败: %v", entry.Name(), err))
+		}
+		result[strings.TrimSuffix(entry.Name(), ".json")] = messages
+	}
+	return result
+}
+
+// Lookup 查找文案，当前语言缺失时回退到默认语言
+func Lookup(locale, key string) (string, bool) {
+	if msg, ok := bundles[locale][key]; ok {
+		return msg, true
+	}
+	msg, ok := bundles[DefaultLocale][key]
+	return msg, ok
+}
+
+// T 翻译消息键，带参数时按 fmt 格式化；目录中没有的键原样返回
+func T(locale, key string, args ...interface{}) string {
+	msg, ok := Lookup(locale, key)
+	if !ok {
+		msg = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Supported 判断是否支持该语言
+func Supported(locale string) bool {
+	_, ok := bundles[locale]
+	return ok
+}
+
+// Negotiate 根据 Accept-Language 选择语言，如 "en-US,en;q=0.9,zh;q=0.8"
+//
+// 先精确匹配（忽略大小写），再按主语言匹配（en -> en-US，zh-TW -> zh-CN），
+// "*" 或都不匹配时返回默认语言
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{tag: tag, q: q})
+	}
+	// q 相同时保持请求头中的先后顺序
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if locale, ok := match(c.tag); ok {
+			return locale
+		}
+	}
+	return DefaultLocale
+}
+
+func match(tag string) (string, bool) {
+	tag = strings.ReplaceAll(tag, "_", "-")
+	if tag == "*" {
+		return DefaultLocale, true
+	}
+	for locale := range bundles {
+		if strings.EqualFold(locale, tag) {
+			return locale, true
+		}
+	}
+
+	primary := strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+	// 默认语言优先，避免多个同主语言的语言包时结果不确定
+	if strings.ToLower(strings.SplitN(DefaultLocale, "-", 2)[0]) == primary {
+		return DefaultLocale, true
+	}
+	locales := make([]string, 0, len(bundles))
+	for locale := range bundles {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	for _, locale := range locales {
+		if strings.ToLower(strings.SplitN(locale, "-", 2)[0]) == primary {
+			return locale, true
+		}
+	}
+	return "", false
+}
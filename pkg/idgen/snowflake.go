@@ -128,3 +128,17 @@ func GenerateRefundNo() string {
 	timestamp := time.Now().Format("20060102150405")
 	return fmt.Sprintf("REF%s%08d", timestamp, id%100000000)
 }
+
+// GenerateEventID 生成事件ID
+func GenerateEventID() string {
+	id := NextID()
+	timestamp := time.Now().Format("20060102150405")
+	return fmt.Sprintf("EVT%s%08d", timestamp, id%100000000)
+}
+
+// GenerateApprovalNo 生成审批单号
+func GenerateApprovalNo() string {
+	id := NextID()
+	timestamp := time.Now().Format("20060102150405")
+	return fmt.Sprintf("APV%s%08d", timestamp, id%100000000)
+}
@@ -0,0 +1,258 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// JWT 校验（HS256 / RS256）
+// ============================================================================
+//
+// 只做校验，不负责签发（令牌由账号中心签发）。
+//
+//   令牌结构：base64url(header) . base64url(claims) . base64url(signature)
+//
+//   HS256：HMAC-SHA256(secret, header.claims)
+//   RS256：RSA PKCS#1 v1.5 + SHA256，公钥从本地 JWKS 文件加载，按 header 中的 kid 选择
+//
+// 校验内容：签名、算法（拒绝 none 以及与密钥类型不符的算法）、exp / nbf、iss、aud。
+//
+// ============================================================================
+
+var (
+	ErrMalformedToken   = errors.New("令牌格式错误")
+	ErrUnsupportedAlg   = errors.New("不支持的签名算法")
+	ErrKeyNotFound      = errors.New("找不到签名密钥")
+	ErrInvalidSignature = errors.New("令牌签名错误")
+	ErrTokenExpired     = errors.New("令牌已过期")
+	ErrTokenNotYetValid = errors.New("令牌尚未生效")
+	ErrInvalidIssuer    = errors.New("令牌签发方不匹配")
+	ErrInvalidAudience  = errors.New("令牌受众不匹配")
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+)
+
+// Claims 标准声明
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+}
+
+// Audience aud 声明，可以是字符串或字符串数组
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+	*a = multi
+	return nil
+}
+
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Options 校验参数
+type Options struct {
+	HMACSecret string        // HS256 密钥，为空表示不接受无 kid 的 HS256 令牌
+	JWKSFile   string        // 本地 JWKS 文件，RSA 公钥（kty=RSA）和 HMAC 密钥（kty=oct）
+	Issuer     string        // 为空不校验
+	Audience   string        // 为空不校验
+	Leeway     time.Duration // 时间校验允许的时钟偏差
+}
+
+// Verifier 令牌校验器
+type Verifier struct {
+	opts     Options
+	rsaKeys  map[string]*rsa.PublicKey
+	hmacKeys map[string][]byte
+}
+
+// NewVerifier 创建校验器，配置了 JWKS 文件时加载其中的密钥
+func NewVerifier(opts Options) (*Verifier, error) {
+	v := &Verifier{
+		opts:     opts,
+		rsaKeys:  make(map[string]*rsa.PublicKey),
+		hmacKeys: make(map[string][]byte),
+	}
+	if opts.JWKSFile != "" {
+		if err := v.loadJWKS(opts.JWKSFile); err != nil {
+			return nil, fmt.Errorf("加载 JWKS 失败: %w", err)
+		}
+	}
+	// 没有任何密钥时所有令牌都校验失败，启动时就报错，而不是上线后所有请求都返回 401
+	if opts.HMACSecret == "" && len(v.rsaKeys) == 0 && len(v.hmacKeys) == 0 {
+		return nil, errors.New("未配置校验密钥：hmac_secret 为空且 JWKS 中没有密钥")
+	}
+	return v, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+func (v *Verifier) loadJWKS(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return err
+	}
+
+	for _, key := range set.Keys {
+		switch key.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(key.N)
+			if err != nil {
+				return fmt.Errorf("kid=%s: n 解码失败: %w", key.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(key.E)
+			if err != nil {
+				return fmt.Errorf("kid=%s: e 解码失败: %w", key.Kid, err)
+			}
+			v.rsaKeys[key.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "oct":
+			k, err := base64.RawURLEncoding.DecodeString(key.K)
+			if err != nil {
+				return fmt.Errorf("kid=%s: k 解码失败: %w", key.Kid, err)
+			}
+			v.hmacKeys[key.Kid] = k
+		}
+	}
+	return nil
+}
+
+// Verify 校验令牌，返回声明
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrMalformedToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	digest := sha256.Sum256(signed)
+
+	switch h.Alg {
+	case AlgHS256:
+		secret, err := v.hmacKey(h.Kid)
+		if err != nil {
+			return nil, err
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return nil, ErrInvalidSignature
+		}
+	case AlgRS256:
+		key, ok := v.rsaKeys[h.Kid]
+		if !ok {
+			return nil, ErrKeyNotFound
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, ErrInvalidSignature
+		}
+	default:
+		return nil, ErrUnsupportedAlg
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformedToken
+	}
+	if err := v.validate(&claims); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+func (v *Verifier) hmacKey(kid string) ([]byte, error) {
+	if kid != "" {
+		if key, ok := v.hmacKeys[kid]; ok {
+			return key, nil
+		}
+		return nil, ErrKeyNotFound
+	}
+	if v.opts.HMACSecret == "" {
+		return nil, ErrKeyNotFound
+	}
+	return []byte(v.opts.HMACSecret), nil
+}
+
+func (v *Verifier) validate(claims *Claims) error {
+	now := time.Now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(v.opts.Leeway)) {
+		return ErrTokenExpired
+	}
+	if claims.NotBefore > 0 && now.Add(v.opts.Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return ErrTokenNotYetValid
+	}
+	if v.opts.Issuer != "" && claims.Issuer != v.opts.Issuer {
+		return ErrInvalidIssuer
+	}
+	if v.opts.Audience != "" && !claims.Audience.Contains(v.opts.Audience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
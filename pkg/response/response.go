@@ -2,6 +2,10 @@ package response
 
 import (
 	"net/http"
+	"strconv"
+	"time"
+
+	"paysystem/pkg/i18n"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,12 +28,47 @@ const (
 	CodeAccountNotFound    = 1005
 	CodePaymentFailed      = 1006
 	CodeRefundFailed       = 1007
+
+	CodeIdempotencyKeyReused = 1008 // 幂等键已用于其他请求
+	CodeRequestProcessing    = 1009 // 相同请求正在处理中
+	CodeTooManyRequests      = 1010 // 请求过于频繁
+	CodeSystemBusy           = 1011 // 锁竞争、并发冲突等暂时性错误，可重试
+	CodeAccountFrozen        = 1012 // 账户已冻结
 )
 
 type Response struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
+	Code      int         `json:"code"`
+	Message   string      `json:"message"`
+	Data      interface{} `json:"data,omitempty"`
+	Retryable bool        `json:"retryable,omitempty"` // 错误是暂时性的，客户端可以原样重试
+}
+
+// ============================================================================
+// 多语言
+// ============================================================================
+//
+// 错误响应的 message 传消息键（见 pkg/i18n 语言包），按请求的 Accept-Language
+// 渲染成对应语言的文案，code 不随语言变化。不在目录里的字符串原样输出。
+//
+// ============================================================================
+
+// LocaleKey 上下文中保存协商结果的 key
+const LocaleKey = "locale"
+
+// Locale 当前请求的语言，首次调用时根据 Accept-Language 协商并缓存在上下文中
+func Locale(c *gin.Context) string {
+	if locale := c.GetString(LocaleKey); locale != "" {
+		return locale
+	}
+	locale := i18n.Negotiate(c.GetHeader("Accept-Language"))
+	c.Set(LocaleKey, locale)
+	c.Header("Content-Language", locale)
+	return locale
+}
+
+// T 按当前请求的语言翻译消息键
+func T(c *gin.Context, key string, args ...interface{}) string {
+	return i18n.T(Locale(c), key, args...)
 }
 
 func Success(c *gin.Context, data interface{}) {
@@ -40,21 +79,63 @@ func Success(c *gin.Context, data interface{}) {
 	})
 }
 
-func Error(c *gin.Context, code int, message string) {
+func Error(c *gin.Context, code int, message string, args ...interface{}) {
 	c.JSON(http.StatusOK, Response{
 		Code:    code,
-		Message: message,
+		Message: T(c, message, args...),
+	})
+}
+
+// Fail 按指定 HTTP 状态码返回错误，message 为已经本地化的文案
+func Fail(c *gin.Context, httpStatus, code int, message string, retryable bool) {
+	c.JSON(httpStatus, Response{
+		Code:      code,
+		Message:   message,
+		Retryable: retryable,
+	})
+}
+
+// Conflict 请求冲突（HTTP 409），如幂等键重复使用、相同请求处理中
+func Conflict(c *gin.Context, code int, message string, args ...interface{}) {
+	c.JSON(http.StatusConflict, Response{
+		Code:    code,
+		Message: T(c, message, args...),
+	})
+}
+
+// TooManyRequests 请求被限流（HTTP 429），Retry-After 为建议的重试等待秒数
+func TooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	c.JSON(http.StatusTooManyRequests, Response{
+		Code:    CodeTooManyRequests,
+		Message: T(c, "common.too_many_requests"),
+	})
+}
+
+// Unauthorized 未通过身份认证（HTTP 401）
+func Unauthorized(c *gin.Context, message string, args ...interface{}) {
+	c.JSON(http.StatusUnauthorized, Response{
+		Code:    CodeUnauthorized,
+		Message: T(c, message, args...),
+	})
+}
+
+// Forbidden 没有访问权限（HTTP 403）
+func Forbidden(c *gin.Context, message string, args ...interface{}) {
+	c.JSON(http.StatusForbidden, Response{
+		Code:    CodeForbidden,
+		Message: T(c, message, args...),
 	})
 }
 
-func ParamError(c *gin.Context, message string) {
-	Error(c, CodeParamError, message)
+func ParamError(c *gin.Context, message string, args ...interface{}) {
+	Error(c, CodeParamError, message, args...)
 }
 
-func ServerError(c *gin.Context, message string) {
-	Error(c, CodeServerError, message)
+func ServerError(c *gin.Context, message string, args ...interface{}) {
+	Error(c, CodeServerError, message, args...)
 }
 
-func BusinessError(c *gin.Context, code int, message string) {
-	Error(c, code, message)
+func BusinessError(c *gin.Context, code int, message string, args ...interface{}) {
+	Error(c, code, message, args...)
 }